@@ -0,0 +1,409 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseMedianValue parses a value for a median voting. The value must be in
+// the format XXXX.XX (see parseConcurrency) and must not be greater than
+// the MaxValue of the voting.
+func ParseMedianValue(str string, voting *MedianVoting) (int, error) {
+	value, err := parseConcurrency(strings.TrimSpace(str))
+	if err != nil {
+		return -1, err
+	}
+	if value > voting.MaxValue {
+		return -1, fmt.Errorf("Value %s is greater than the maximum value %s",
+			FormatConcurrency(value), FormatConcurrency(voting.MaxValue))
+	}
+	return value, nil
+}
+
+// ParseSchulzeRanking parses a ranking for a schulze voting. positions must
+// contain the position of each option of the voting, each position must be
+// a number between 1 and the number of options or empty for an option that
+// is Unranked. Equal positions are allowed, see SchulzeVote.
+// At least one option must be ranked, an abstention must be given
+// explicitly as in ParseBallots.
+func ParseSchulzeRanking(positions []string, voting *SchulzeVoting) ([]int, error) {
+	n := len(voting.Options)
+	if len(positions) != n {
+		return nil, fmt.Errorf("Expected ranking of length %d, got length %d", n, len(positions))
+	}
+	res := make([]int, n)
+	for i, str := range positions {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			res[i] = Unranked
+			continue
+		}
+		position, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid position for option \"%s\": %s", voting.Options[i], err.Error())
+		}
+		if position < 1 || position > n {
+			return nil, fmt.Errorf("Position for option \"%s\" must be between 1 and %d", voting.Options[i], n)
+		}
+		res[i] = position
+	}
+	if RankingAbstains(res) {
+		return nil, errors.New("No option ranked, abstain explicitly to abstain")
+	}
+	return res, nil
+}
+
+// MedianBallot is the vote of a voter in a median voting, Value is 0 if
+// the voter abstained.
+type MedianBallot struct {
+	Voter   *Voter
+	Voting  *MedianVoting
+	Value   int
+	Abstain bool
+}
+
+// SchulzeBallot is the vote of a voter in a schulze voting, Ranking is a
+// ranking as described in SchulzeVote. Ranking is nil if the voter
+// abstained.
+type SchulzeBallot struct {
+	Voter   *Voter
+	Voting  *SchulzeVoting
+	Ranking []int
+	Abstain bool
+}
+
+// Ballots contains all ballots from a ballots file, see ParseBallots.
+type Ballots struct {
+	Median  []*MedianBallot
+	Schulze []*SchulzeBallot
+}
+
+// MedianVotes returns the votes for the given voting, the result can be
+// used in EvaluateMedian.
+func (ballots *Ballots) MedianVotes(voting *MedianVoting) []*MedianVote {
+	res := make([]*MedianVote, 0)
+	for _, ballot := range ballots.Median {
+		if ballot.Voting == voting {
+			res = append(res, &MedianVote{Weight: ballot.Voter.Weight, Value: ballot.Value,
+				Voter: ballot.Voter.Name, Abstain: ballot.Abstain})
+		}
+	}
+	return res
+}
+
+// SchulzeVotes returns the votes for the given voting, the result can be
+// used in EvaluateSchulze.
+func (ballots *Ballots) SchulzeVotes(voting *SchulzeVoting) []*SchulzeVote {
+	res := make([]*SchulzeVote, 0)
+	for _, ballot := range ballots.Schulze {
+		switch {
+		case ballot.Voting != voting:
+		case ballot.Abstain:
+			res = append(res, NewSchulzeAbstention(ballot.Voter.Weight))
+		default:
+			res = append(res, NewSchulzeVote(ballot.Voter.Weight, ballot.Ranking))
+		}
+	}
+	return res
+}
+
+// ballotVoting is either a median or a schulze voting referenced in a
+// ballots file.
+type ballotVoting struct {
+	median  *MedianVoting
+	schulze *SchulzeVoting
+}
+
+// ballotKey is used to find duplicate votes of a voter in a voting.
+type ballotKey struct {
+	voter  *Voter
+	voting ballotVoting
+}
+
+var votingIndexRegex = regexp.MustCompile(`^(.+?)\s*\[(\d+)\]$`)
+
+// ballotsParser holds the state while parsing a ballots file.
+type ballotsParser struct {
+	voters  map[string]*Voter
+	votings map[string][]ballotVoting
+	res     *Ballots
+	voted   map[ballotKey]bool
+	// the voter, ballot and voting line of the last voter / voting
+	voter      *Voter
+	median     *MedianBallot
+	schulze    *SchulzeBallot
+	hasValue   bool
+	ranked     []bool
+	votingLine int
+}
+
+func newBallotsParser(voters []*Voter, collection *VotingCollection) *ballotsParser {
+	p := &ballotsParser{voters: make(map[string]*Voter, len(voters)),
+		votings: make(map[string][]ballotVoting),
+		res:     &Ballots{Median: make([]*MedianBallot, 0), Schulze: make([]*SchulzeBallot, 0)},
+		voted:   make(map[ballotKey]bool)}
+	for _, voter := range voters {
+		p.voters[voter.Name] = voter
+	}
+	for _, group := range collection.Groups {
+		for _, voting := range group.MedianVotings {
+			p.votings[voting.Name] = append(p.votings[voting.Name], ballotVoting{median: voting})
+		}
+		for _, voting := range group.SchulzeVotings {
+			p.votings[voting.Name] = append(p.votings[voting.Name], ballotVoting{schulze: voting})
+		}
+	}
+	return p
+}
+
+// finishVoting checks that the ballot for the last voting is complete and
+// adds it to the result.
+func (p *ballotsParser) finishVoting() error {
+	switch {
+	case p.median != nil:
+		if !p.hasValue {
+			return NewSyntaxError(p.votingLine, fmt.Sprintf("No value given for voting \"%s\"", p.median.Voting.Name))
+		}
+		p.res.Median = append(p.res.Median, p.median)
+	case p.schulze != nil:
+		if !p.schulze.Abstain && RankingAbstains(p.schulze.Ranking) {
+			return NewSyntaxError(p.votingLine, fmt.Sprintf("No option ranked in voting \"%s\", use \"- abstain\" to abstain",
+				p.schulze.Voting.Name))
+		}
+		p.res.Schulze = append(p.res.Schulze, p.schulze)
+	}
+	p.median, p.schulze, p.hasValue, p.ranked = nil, nil, false, nil
+	return nil
+}
+
+func (p *ballotsParser) parseVoter(line string, lineNumber int) error {
+	name := strings.TrimSpace(line[1:])
+	voter, has := p.voters[name]
+	if !has {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Unknown voter \"%s\"", name))
+	}
+	p.voter = voter
+	return nil
+}
+
+func (p *ballotsParser) parseVoting(line string, lineNumber int) error {
+	if p.voter == nil {
+		return NewSyntaxError(lineNumber, "Expected a voter starting with # ")
+	}
+	name := strings.TrimSpace(line[2:])
+	// the voting can be given as NAME [i] to reference the i-th voting with
+	// that name
+	index, indexGiven := 0, false
+	if match := votingIndexRegex.FindStringSubmatch(name); match != nil {
+		if _, has := p.votings[name]; !has {
+			name = match[1]
+			index, _ = strconv.Atoi(match[2])
+			index--
+			indexGiven = true
+		}
+	}
+	candidates := p.votings[name]
+	switch {
+	case len(candidates) == 0:
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Unknown voting \"%s\"", name))
+	case index < 0 || index >= len(candidates):
+		return NewSyntaxError(lineNumber, fmt.Sprintf("There are only %d votings with name \"%s\"", len(candidates), name))
+	case len(candidates) > 1 && !indexGiven:
+		return NewSyntaxError(lineNumber, fmt.Sprintf("There are %d votings with name \"%s\", use \"%s [i]\" to reference the i-th one",
+			len(candidates), name, name))
+	}
+	voting := candidates[index]
+	key := ballotKey{voter: p.voter, voting: voting}
+	if p.voted[key] {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Voter \"%s\" already voted in voting \"%s\"", p.voter.Name, name))
+	}
+	p.voted[key] = true
+	p.votingLine = lineNumber
+	if voting.median != nil {
+		p.median = &MedianBallot{Voter: p.voter, Voting: voting.median}
+	} else {
+		ranking := make([]int, len(voting.schulze.Options))
+		for i := range ranking {
+			ranking[i] = Unranked
+		}
+		p.schulze = &SchulzeBallot{Voter: p.voter, Voting: voting.schulze, Ranking: ranking}
+		p.ranked = make([]bool, len(voting.schulze.Options))
+	}
+	return nil
+}
+
+// abstainValue is the value of a voter that abstains.
+const abstainValue = "abstain"
+
+func (p *ballotsParser) parseValue(line string, lineNumber int) error {
+	if p.schulze != nil {
+		return p.parseSchulzeAbstention(line, lineNumber)
+	}
+	if p.median == nil {
+		return NewSyntaxError(lineNumber, "Got a value without a median voting")
+	}
+	if p.hasValue {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Got more than one value for voting \"%s\"", p.median.Voting.Name))
+	}
+	if strings.TrimSpace(line[1:]) == abstainValue {
+		p.median.Abstain = true
+		p.hasValue = true
+		return nil
+	}
+	value, err := ParseMedianValue(line[1:], p.median.Voting)
+	if err != nil {
+		return NewSyntaxError(lineNumber, err.Error())
+	}
+	p.median.Value = value
+	p.hasValue = true
+	return nil
+}
+
+// parseSchulzeAbstention parses a value in a schulze voting, the only
+// value allowed is abstainValue.
+func (p *ballotsParser) parseSchulzeAbstention(line string, lineNumber int) error {
+	if value := strings.TrimSpace(line[1:]); value != abstainValue {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Expected \"- %s\" in schulze voting \"%s\", got \"%s\"",
+			abstainValue, p.schulze.Voting.Name, value))
+	}
+	if p.schulze.Abstain || !RankingAbstains(p.schulze.Ranking) {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Voter \"%s\" can't both rank options and abstain in voting \"%s\"",
+			p.voter.Name, p.schulze.Voting.Name))
+	}
+	p.schulze.Abstain = true
+	p.schulze.Ranking = nil
+	return nil
+}
+
+func (p *ballotsParser) parseOption(line string, lineNumber int) error {
+	if p.schulze == nil {
+		return NewSyntaxError(lineNumber, "Got an option without a schulze voting")
+	}
+	if p.schulze.Abstain {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Voter \"%s\" can't both rank options and abstain in voting \"%s\"",
+			p.voter.Name, p.schulze.Voting.Name))
+	}
+	line = line[1:]
+	lastColon := strings.LastIndex(line, ":")
+	if lastColon < 0 {
+		return NewSyntaxError(lineNumber, "Line must contain \": position\"")
+	}
+	option, positionStr := strings.TrimSpace(line[:lastColon]), strings.TrimSpace(line[lastColon+1:])
+	position, err := strconv.Atoi(positionStr)
+	if err != nil {
+		return NewSyntaxError(lineNumber, err.Error())
+	}
+	voting := p.schulze.Voting
+	if position < 1 || position > len(voting.Options) {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Position must be between 1 and %d", len(voting.Options)))
+	}
+	index := -1
+	for i, o := range voting.Options {
+		if o == option {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Unknown option \"%s\" in voting \"%s\"", option, voting.Name))
+	}
+	if p.ranked[index] {
+		return NewSyntaxError(lineNumber, fmt.Sprintf("Option \"%s\" was already ranked", option))
+	}
+	p.schulze.Ranking[index] = position
+	p.ranked[index] = true
+	return nil
+}
+
+// ParseBallots parses ballots from r. The format is
+//
+//	# VOTER-NAME
+//	## VOTING-NAME
+//	* OPTION: POSITION
+//	* OPTION: POSITION
+//	## VOTING-NAME
+//	- VALUE
+//	## VOTING-NAME
+//	- abstain
+//
+// Voter names must be names from voters, voting names and options must be
+// names from the collection. If there are several votings with the same
+// name use "VOTING-NAME [i]" to reference the i-th voting with that name
+// (starting with 1).
+// For a schulze voting each option that is ranked must get a position
+// between 1 and the number of options, options may have the same position
+// and options that are not listed are Unranked (see SchulzeVote). At least
+// one option must be ranked, a voter that abstains writes "- abstain"
+// instead of the options.
+// For a median voting the value must be given in the format XXXX.XX and
+// must not be greater than the MaxValue of the voting, a voter that
+// abstains writes "- abstain" instead of the value.
+// Each voter may vote only once in each voting.
+// All errors are returned as a SyntaxError.
+func ParseBallots(r io.Reader, voters []*Voter, collection *VotingCollection) (*Ballots, error) {
+	p := newBallotsParser(voters, collection)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 1
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			lineNumber++
+			continue
+		}
+		var err error
+		switch {
+		case strings.HasPrefix(line, "# "):
+			if err = p.finishVoting(); err == nil {
+				err = p.parseVoter(line, lineNumber)
+			}
+		case strings.HasPrefix(line, "## "):
+			if err = p.finishVoting(); err == nil {
+				err = p.parseVoting(line, lineNumber)
+			}
+		case strings.HasPrefix(line, "- "):
+			err = p.parseValue(line, lineNumber)
+		case strings.HasPrefix(line, "* "):
+			err = p.parseOption(line, lineNumber)
+		default:
+			err = NewSyntaxError(lineNumber, "Expected a voter (#), a voting (##), an option (*) or a value (-)")
+		}
+		if err != nil {
+			return nil, err
+		}
+		lineNumber++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.finishVoting(); err != nil {
+		return nil, err
+	}
+	return p.res, nil
+}
@@ -0,0 +1,197 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMedianValue(t *testing.T) {
+	voting := &MedianVoting{Name: "Test", MaxValue: 108660}
+	valid := map[string]int{"1086,6": 108660, "10": 1000, "0.05": 5, " 500.5 ": 50050}
+	for str, expected := range valid {
+		value, err := ParseMedianValue(str, voting)
+		if err != nil {
+			t.Errorf("Expected \"%s\" to be valid, got error %s", str, err.Error())
+			continue
+		}
+		if value != expected {
+			t.Errorf("Expected value %d for \"%s\", got %d", expected, str, value)
+		}
+	}
+	for _, str := range []string{"", "abc", "10.123", "-5", "1086.61"} {
+		if _, err := ParseMedianValue(str, voting); err == nil {
+			t.Errorf("Expected \"%s\" to be invalid", str)
+		}
+	}
+}
+
+func TestParseSchulzeRanking(t *testing.T) {
+	voting := &SchulzeVoting{Name: "Test", Options: []string{"A", "B", "C"}}
+	ranking, err := ParseSchulzeRanking([]string{"1", "2", "1"}, voting)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !compareSlices([][]int{ranking}, [][]int{[]int{1, 2, 1}}) {
+		t.Errorf("Expected ranking [1, 2, 1], got %v", ranking)
+	}
+	invalid := [][]string{{"1", "2"}, {"1", "2", "4"}, {"0", "1", "2"}, {"1", "x", "2"}, {"", " ", ""}}
+	for _, positions := range invalid {
+		if _, err := ParseSchulzeRanking(positions, voting); err == nil {
+			t.Errorf("Expected ranking %v to be invalid", positions)
+		}
+	}
+}
+
+func parseTestCollection(t *testing.T) (*VotingCollection, []*Voter) {
+	collection, err := ParseVotingCollection(strings.NewReader(`# Test: 01.01.2017
+## Group
+### Schulze
+* A
+* B
+* No
+### Twice
+* Yes
+* No
+### Twice
+* Yes
+* No
+### Median
+- 100`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	voters := []*Voter{NewVoter("V1", 2), NewVoter("V2", 1)}
+	return collection, voters
+}
+
+func TestParseBallots(t *testing.T) {
+	collection, voters := parseTestCollection(t)
+	ballots, err := ParseBallots(strings.NewReader(`# V1
+## Schulze
+* A: 1
+* No: 2
+* B: 1
+## Twice [2]
+* Yes: 1
+* No: 2
+## Median
+- 50,5
+
+# V2
+## Median
+- 100`), voters, collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ballots.Schulze) != 2 || len(ballots.Median) != 2 {
+		t.Fatalf("Expected 2 schulze and 2 median ballots, got %d and %d",
+			len(ballots.Schulze), len(ballots.Median))
+	}
+	group := collection.Groups[0]
+	schulzeVotes := ballots.SchulzeVotes(group.SchulzeVotings[0])
+	if len(schulzeVotes) != 1 || !compareSlices([][]int{schulzeVotes[0].Ranking}, [][]int{{1, 1, 2}}) {
+		t.Errorf("Wrong schulze votes for first voting")
+	}
+	if len(ballots.SchulzeVotes(group.SchulzeVotings[1])) != 0 {
+		t.Errorf("Expected no votes for the first voting \"Twice\"")
+	}
+	if len(ballots.SchulzeVotes(group.SchulzeVotings[2])) != 1 {
+		t.Errorf("Expected one vote for the second voting \"Twice\"")
+	}
+	medianVotes := ballots.MedianVotes(group.MedianVotings[0])
+	if len(medianVotes) != 2 || medianVotes[0].Value != 5050 || medianVotes[0].Weight != 2 {
+		t.Errorf("Wrong median votes")
+	}
+}
+
+func TestParseBallotsAbstentions(t *testing.T) {
+	collection, voters := parseTestCollection(t)
+	ballots, err := ParseBallots(strings.NewReader(`# V1
+## Schulze
+* B: 1
+
+# V2
+## Schulze
+- abstain
+## Median
+- abstain`), voters, collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	votes := ballots.SchulzeVotes(collection.Groups[0].SchulzeVotings[0])
+	if len(votes) != 2 {
+		t.Fatalf("Expected 2 schulze votes, got %d", len(votes))
+	}
+	if votes[0].Abstain || !compareSlices([][]int{votes[0].Ranking}, [][]int{{Unranked, 1, Unranked}}) {
+		t.Errorf("Expected partial ranking [Unranked 1 Unranked], got %v", votes[0].Ranking)
+	}
+	if !votes[1].Abstain || votes[1].Weight != 1 {
+		t.Errorf("Expected abstention with weight 1, got %+v", votes[1])
+	}
+	medianVotes := ballots.MedianVotes(collection.Groups[0].MedianVotings[0])
+	if len(medianVotes) != 1 || !medianVotes[0].Abstain {
+		t.Errorf("Expected a median abstention, got %v", medianVotes)
+	}
+}
+
+func TestParseBallotsErrors(t *testing.T) {
+	collection, voters := parseTestCollection(t)
+	// maps the input to the line in which the error is expected
+	invalid := map[string]int{
+		"# V3":                                1,
+		"# V1\n## Unknown":                    2,
+		"## Median\n- 100":                    1,
+		"# V1\n## Twice\n* Yes: 1\n* No: 2":   2,
+		"# V1\n## Median\n- 100.01":           3,
+		"# V1\n## Median\n- 10\n- 20":         4,
+		"# V1\n## Median\n- 10\n## Median":    4,
+		"# V1\n## Schulze\n* C: 1":            3,
+		"# V1\n## Schulze\n* A: 1\n* A: 2":    4,
+		"# V1\n## Schulze\n* A: 4":            3,
+		"# V1\n## Schulze\n":                  2,
+		"# V1\n## Schulze\n- 10":              3,
+		"# V1\n## Schulze\n* A: 1\n- abstain": 4,
+		"# V1\n## Schulze\n- abstain\n* A: 1": 4,
+		"# V1\n## Median\n\n# V2":             2,
+		"# V1\n## Twice [3]":                  2,
+		"# V1\nfoo":                           2,
+	}
+	for input, line := range invalid {
+		_, err := ParseBallots(strings.NewReader(input), voters, collection)
+		if err == nil {
+			t.Errorf("Expected an error for input %q", input)
+			continue
+		}
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Expected a SyntaxError for input %q, got %v", input, err)
+			continue
+		}
+		if syntaxErr.lineNumber != line {
+			t.Errorf("Expected error in line %d for input %q, got %s", line, input, err.Error())
+		}
+	}
+}
@@ -0,0 +1,711 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/FabianWe/sturavoting"
+	"gopkg.in/yaml.v2"
+)
+
+// command is a subcommand of the program.
+type command struct {
+	// usage describes the arguments of the command.
+	usage string
+	run   func(appContext *sturavoting.VotingContext, args []string) error
+	// offline is true for commands that don't need a configuration and
+	// database, they're called with a context using an empty
+	// sturavoting.MemoryStorage.
+	offline bool
+}
+
+// usageError is returned by commands if they're called with invalid
+// arguments.
+type usageError string
+
+func (err usageError) Error() string {
+	return string(err)
+}
+
+var commands = map[string]*command{
+	"category add":      {"NAME", categoryAdd, false},
+	"category list":     {"", categoryList, false},
+	"revision add":      {"CATEGORY-ID", revisionAdd, false},
+	"revision list":     {"[-category ID]", revisionList, false},
+	"revision clone":    {"REVISION-ID [-diff FILE]", revisionClone, false},
+	"voters import":     {"FILE -revision ID [-format FORMAT]", votersImport, false},
+	"voters list":       {"-revision ID", votersList, false},
+	"voters export":     {"-revision ID [-format FORMAT]", votersExport, false},
+	"voters compare":    {"OLD-REVISION-ID NEW-REVISION-ID [-format FORMAT]", votersCompare, false},
+	"collection import": {"FILE -revision ID [-format FORMAT]", collectionImport, false},
+	"collection list":   {"[-revision ID]", collectionList, false},
+	"collection export": {"COLLECTION-ID [-format FORMAT]", collectionExport, false},
+	"serve":             {"[-templates DIR]", serve, false},
+	"user add":          {"NAME [-admin] [-password PASSWORD]", userAdd, false},
+	"user link":         {"NAME -voter ID", userLink, false},
+	"evaluate":          {"VOTERS-FILE COLLECTION-FILE BALLOTS-FILE [-format FORMAT] [-basis BASIS] [-present WEIGHT] [-quorum FRACTION] [-majority FRACTION] [-ties MODE] [-seed SEED]", evaluate, true},
+	"migrate":           {"", migrate, false},
+}
+
+// parseArgs parses the flags in args with fs, flags may appear before or
+// after the positional arguments. It returns the positional arguments and
+// checks that there are exactly n of them.
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	fs.SetOutput(ioutil.Discard)
+	positional := make([]string, 0, n)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageError(err.Error())
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != n {
+		return nil, usageError(fmt.Sprintf("Expected %d argument(s), got %d", n, len(positional)))
+	}
+	return positional, nil
+}
+
+// parseID parses an id given as argument.
+func parseID(str string) (uint, error) {
+	id, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return sturavoting.InvalidID, usageError(fmt.Sprintf("Invalid id \"%s\"", str))
+	}
+	return uint(id), nil
+}
+
+// idFlag is a flag containing an id, it is sturavoting.InvalidID if the flag
+// was not set.
+type idFlag uint
+
+func newIDFlag(fs *flag.FlagSet, name, usage string) *idFlag {
+	res := idFlag(sturavoting.InvalidID)
+	fs.Var(&res, name, usage)
+	return &res
+}
+
+func (f *idFlag) String() string {
+	if f == nil || uint(*f) == sturavoting.InvalidID {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*f), 10)
+}
+
+func (f *idFlag) Set(str string) error {
+	id, err := parseID(str)
+	if err != nil {
+		return err
+	}
+	*f = idFlag(id)
+	return nil
+}
+
+// required returns the id or a usage error if the flag was not set.
+func (f *idFlag) required(name string) (uint, error) {
+	if uint(*f) == sturavoting.InvalidID {
+		return sturavoting.InvalidID, usageError(fmt.Sprintf("Flag -%s is required", name))
+	}
+	return uint(*f), nil
+}
+
+// newFormatFlag adds the -format flag for the file format to fs.
+func newFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "text", "The file format: text, json or yaml.")
+}
+
+// checkFormat returns a usage error if format is not a valid file format.
+func checkFormat(format string) error {
+	switch format {
+	case "text", "json", "yaml":
+		return nil
+	default:
+		return usageError(fmt.Sprintf("Invalid format \"%s\", expected text, json or yaml", format))
+	}
+}
+
+// writeFormatted writes v as JSON or YAML to stdout, for the text format
+// writeText is called.
+func writeFormatted(format string, v interface{}, writeText func() error) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	default:
+		return writeText()
+	}
+}
+
+func categoryAdd(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("category add", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := appContext.Storage.InsertCategory(positional[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created category %d\n", id)
+	return nil
+}
+
+func categoryList(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("category list", flag.ContinueOnError)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	categories, err := appContext.Storage.ListCategories()
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		fmt.Printf("%d\t%s\t%s\n", c.ID, c.Created.Format("02.01.2006 15:04"), c.Name)
+	}
+	return nil
+}
+
+func revisionAdd(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("revision add", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	categoryID, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+	id, err := appContext.Storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created revision %d\n", id)
+	return nil
+}
+
+func revisionList(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("revision list", flag.ContinueOnError)
+	categoryID := newIDFlag(fs, "category", "Only list revisions in this category.")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	revisions, err := appContext.Storage.ListVotersRevision(uint(*categoryID))
+	if err != nil {
+		return err
+	}
+	for _, r := range revisions {
+		fmt.Printf("%d\tcategory %d\t%s\n", r.ID, r.CategoryID, r.Created.Format("02.01.2006 15:04"))
+	}
+	return nil
+}
+
+func revisionClone(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("revision clone", flag.ContinueOnError)
+	diffFile := fs.String("diff", "", "File with the changes to apply to the voters.")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	revisionID, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+	diff := sturavoting.NewVotersDiff()
+	if *diffFile != "" {
+		err = parseFile(*diffFile, func(f *os.File) (err error) {
+			diff, err = sturavoting.ParseVotersDiff(f)
+			return
+		})
+		if err != nil {
+			return err
+		}
+	}
+	id, err := appContext.Storage.CloneVotersRevision(revisionID, diff)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created revision %d\n", id)
+	return nil
+}
+
+func votersImport(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("voters import", flag.ContinueOnError)
+	revisionFlag := newIDFlag(fs, "revision", "The revision to import the voters into.")
+	format := newFormatFlag(fs)
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	revisionID, err := revisionFlag.required("revision")
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	var voters []*sturavoting.Voter
+	err = parseFile(positional[0], func(f *os.File) (err error) {
+		switch *format {
+		case "json":
+			voters, err = sturavoting.DecodeVotersJSON(f)
+		case "yaml":
+			voters, err = sturavoting.DecodeVotersYAML(f)
+		default:
+			voters, err = sturavoting.ParseVoters(f)
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if err := appContext.Storage.InsertVoters(revisionID, voters); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d voters into revision %d\n", len(voters), revisionID)
+	return nil
+}
+
+func votersList(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("voters list", flag.ContinueOnError)
+	revisionFlag := newIDFlag(fs, "revision", "The revision to list the voters of.")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	revisionID, err := revisionFlag.required("revision")
+	if err != nil {
+		return err
+	}
+	voters, err := appContext.Storage.ListVoters(revisionID)
+	if err != nil {
+		return err
+	}
+	for _, v := range voters {
+		fmt.Printf("%d\t%s\t%d\n", v.ID, v.Name, v.Weight)
+	}
+	return nil
+}
+
+func votersExport(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("voters export", flag.ContinueOnError)
+	revisionFlag := newIDFlag(fs, "revision", "The revision to export the voters of.")
+	format := newFormatFlag(fs)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	revisionID, err := revisionFlag.required("revision")
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	voters, err := appContext.Storage.ListVoters(revisionID)
+	if err != nil {
+		return err
+	}
+	return writeFormatted(*format, voters, func() error {
+		return sturavoting.WriteVoters(os.Stdout, voters)
+	})
+}
+
+func votersCompare(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("voters compare", flag.ContinueOnError)
+	format := newFormatFlag(fs)
+	positional, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	oldID, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+	newID, err := parseID(positional[1])
+	if err != nil {
+		return err
+	}
+	comparison, err := sturavoting.CompareRevisions(appContext.Storage, oldID, newID)
+	if err != nil {
+		return err
+	}
+	return writeFormatted(*format, comparison, func() error {
+		printComparison(comparison)
+		return nil
+	})
+}
+
+// printComparison prints the report of CompareRevisions.
+func printComparison(comparison *sturavoting.VotersComparison) {
+	fmt.Printf("Added (%d):\n", len(comparison.Added))
+	for _, v := range comparison.Added {
+		fmt.Printf("  + %s: %d\n", v.Name, v.Weight)
+	}
+	fmt.Printf("Removed (%d):\n", len(comparison.Removed))
+	for _, v := range comparison.Removed {
+		fmt.Printf("  - %s: %d\n", v.Name, v.Weight)
+	}
+	fmt.Printf("Weight changed (%d):\n", len(comparison.Changed))
+	for _, c := range comparison.Changed {
+		fmt.Printf("  ~ %s: %d -> %d\n", c.Name, c.OldWeight, c.NewWeight)
+	}
+	fmt.Printf("Total weight: %d -> %d\n", comparison.OldWeight, comparison.NewWeight)
+}
+
+func collectionImport(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("collection import", flag.ContinueOnError)
+	revisionFlag := newIDFlag(fs, "revision", "The voters revision for the collection.")
+	format := newFormatFlag(fs)
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	revisionID, err := revisionFlag.required("revision")
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	var collection *sturavoting.VotingCollection
+	err = parseFile(positional[0], func(f *os.File) (err error) {
+		switch *format {
+		case "json":
+			collection, err = sturavoting.DecodeVotingCollectionJSON(f)
+		case "yaml":
+			collection, err = sturavoting.DecodeVotingCollectionYAML(f)
+		default:
+			collection, err = sturavoting.ParseVotingCollection(f)
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if err := appContext.Storage.InsertVotingCollection(revisionID, collection); err != nil {
+		return err
+	}
+	fmt.Printf("Created collection %d\n", collection.ID)
+	return nil
+}
+
+func collectionList(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("collection list", flag.ContinueOnError)
+	revisionID := newIDFlag(fs, "revision", "Only list collections for this revision.")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	collections, err := appContext.Storage.ListVotingCollections(uint(*revisionID))
+	if err != nil {
+		return err
+	}
+	for _, c := range collections {
+		fmt.Printf("%d\trevision %d\t%s\t%s\n", c.ID, c.VotersID, c.Date.Format("02.01.2006"), c.Name)
+	}
+	return nil
+}
+
+func collectionExport(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("collection export", flag.ContinueOnError)
+	format := newFormatFlag(fs)
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	collectionID, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	collection, err := appContext.Storage.GetVotingCollection(collectionID)
+	if err != nil {
+		return err
+	}
+	return writeFormatted(*format, collection, func() error {
+		return sturavoting.WriteVotingCollection(os.Stdout, collection)
+	})
+}
+
+func serve(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	templateDir := fs.String("templates", "./templates", "Directory containing the html templates.")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	return appContext.ListenAndServe(*templateDir)
+}
+
+func migrate(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	storage, ok := appContext.Storage.(*sturavoting.SQLStorage)
+	if !ok {
+		return errors.New("Only databases can be migrated")
+	}
+	applied, err := storage.Migrate()
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Printf("Database schema is up to date (version %d)\n", sturavoting.LatestSchemaVersion)
+	} else {
+		fmt.Printf("Migrated database schema from version %d to %d\n", appContext.SchemaVersion, applied[len(applied)-1])
+	}
+	return nil
+}
+
+// readPassword reads the password from stdin.
+func readPassword() (string, error) {
+	fmt.Print("Password: ")
+	reader := bufio.NewReader(os.Stdin)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func userAdd(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("user add", flag.ContinueOnError)
+	admin := fs.Bool("admin", false, "Grant the administrator role to the user.")
+	password := fs.String("password", "", "The password of the user, read from stdin if empty.")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *password == "" {
+		if *password, err = readPassword(); err != nil {
+			return err
+		}
+	}
+	if *password == "" {
+		return usageError("Password must not be empty")
+	}
+	userID, err := appContext.UserHandler.Insert(positional[0], "", "", "", []byte(*password))
+	if err != nil {
+		return err
+	}
+	if *admin {
+		if err := appContext.Storage.SetAdmin(userID, true); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Created user %d\n", userID)
+	return nil
+}
+
+func userLink(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("user link", flag.ContinueOnError)
+	voterFlag := newIDFlag(fs, "voter", "The voter the user may vote for.")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	voterID, err := voterFlag.required("voter")
+	if err != nil {
+		return err
+	}
+	userID, err := appContext.UserIDByName(positional[0])
+	if err != nil {
+		return err
+	}
+	return appContext.Storage.LinkUserToVoter(userID, voterID)
+}
+
+// parseFile opens the file and parses it with parse.
+func parseFile(path string, parse func(f *os.File) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := parse(f); err != nil {
+		return fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return nil
+}
+
+func evaluate(appContext *sturavoting.VotingContext, args []string) error {
+	fs := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	format := fs.String("format", "text", "The output format: text, json or yaml.")
+	basis := fs.String("basis", "cast", "The weight majorities are computed from: cast, present or eligible.")
+	present := fs.Int("present", 0, "The weight of the voters present, required for -basis present.")
+	quorum := fs.String("quorum", "", "The fraction of the weight of all voters that must vote, for example 1/2.")
+	majority := fs.String("majority", "", "The majority of votings without a majority, for example 1/2. Votings without a majority are an error if not given.")
+	ties := fs.String("ties", "report", "How ties in schulze votings are broken: report, random or ballots.")
+	seed := fs.Int64("seed", 0, "The seed for breaking ties.")
+	positional, err := parseArgs(fs, args, 3)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	options := &sturavoting.EvaluationOptions{Present: *present}
+	if options.Basis, err = sturavoting.ParseMajorityBasis(*basis); err != nil {
+		return usageError(err.Error())
+	}
+	if *quorum != "" {
+		if options.Quorum, err = sturavoting.ParseQuorum(*quorum); err != nil {
+			return usageError(err.Error())
+		}
+	}
+	if *majority != "" {
+		fraction, err := sturavoting.ParsePercentRequired(*majority)
+		if err != nil {
+			return usageError(err.Error())
+		}
+		defaultMajority := sturavoting.Majority(fraction)
+		options.DefaultMajority = &defaultMajority
+	}
+	mode, err := sturavoting.ParseTieBreaking(*ties)
+	if err != nil {
+		return usageError(err.Error())
+	}
+	options.TieBreaker = &sturavoting.TieBreaker{Mode: mode, Seed: *seed}
+	if err := options.Validate(); err != nil {
+		return usageError(err.Error())
+	}
+	var voters []*sturavoting.Voter
+	var collection *sturavoting.VotingCollection
+	var ballots *sturavoting.Ballots
+	err = parseFile(positional[0], func(f *os.File) (err error) {
+		voters, err = sturavoting.ParseVoters(f)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	err = parseFile(positional[1], func(f *os.File) (err error) {
+		collection, err = sturavoting.ParseVotingCollection(f)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	err = parseFile(positional[2], func(f *os.File) (err error) {
+		ballots, err = sturavoting.ParseBallots(f, voters, collection)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	results, err := sturavoting.EvaluateBallots(collection, voters, ballots, options)
+	switch err.(type) {
+	case nil:
+	case *sturavoting.MajorityError:
+		if options.DefaultMajority == nil {
+			return usageError(fmt.Sprintf("%s, set the majority of votings without a majority with -majority", err.Error()))
+		}
+		return err
+	case *sturavoting.EvaluationOptionsError:
+		return usageError(err.Error())
+	default:
+		return err
+	}
+	return writeFormatted(*format, results, func() error {
+		printResults(collection, results)
+		return nil
+	})
+}
+
+func printResults(collection *sturavoting.VotingCollection, results []*sturavoting.GroupResult) {
+	fmt.Printf("%s: %s\n", collection.Name, collection.Date.Format("02.01.2006"))
+	for _, groupRes := range results {
+		fmt.Printf("\n%s\n", groupRes.Group.Name)
+		for _, votingRes := range groupRes.Results() {
+			if res := votingRes.Median; res != nil {
+				fmt.Printf("\n  %s\n", res.Voting.Name)
+				printMajority(res.NumVotes, res.PercentRequired, &res.MajorityResult)
+				for _, step := range res.Steps {
+					marker := ""
+					if step.Majority {
+						marker = " *"
+					}
+					fmt.Printf("    %s: %d%s\n", sturavoting.FormatConcurrency(step.Value), step.Weight, marker)
+				}
+				if len(res.Abstentions) > 0 {
+					fmt.Printf("    Abstentions: %s\n", strings.Join(res.Abstentions, ", "))
+				}
+				if len(res.NotVoted) > 0 {
+					fmt.Printf("    Not voted: %s\n", strings.Join(res.NotVoted, ", "))
+				}
+				if res.Valid {
+					fmt.Printf("    Result: %s (requested %s)\n", sturavoting.FormatConcurrency(res.Value), sturavoting.FormatConcurrency(res.Voting.MaxValue))
+				} else {
+					fmt.Printf("    No result (requested %s)\n", sturavoting.FormatConcurrency(res.Voting.MaxValue))
+				}
+				continue
+			}
+			res := votingRes.Schulze
+			fmt.Printf("\n  %s\n", res.Voting.Name)
+			printMajority(res.NumVotes, res.PercentRequired, &res.MajorityResult)
+			for i, options := range res.RankedOptions {
+				fmt.Printf("    %d. %s\n", i+1, strings.Join(options, ", "))
+			}
+			statusQuo := res.Voting.Options[res.StatusQuo]
+			for i, option := range res.Voting.Options {
+				if i != res.StatusQuo {
+					fmt.Printf("    %s: %s (%.2f%% of the %s weight before %s)\n", option, res.Verdicts[i], res.Percents[i]*100.0, res.Basis, statusQuo)
+				}
+			}
+		}
+	}
+}
+
+func printMajority(numVotes int, percentRequired float64, res *sturavoting.MajorityResult) {
+	fmt.Printf("    Votes: %d (weight %d), required: more than %d of %d (%.2f%% of the %s weight)\n",
+		numVotes, res.CastWeight, res.VotesRequired, res.ReferenceWeight, percentRequired*100.0, res.Basis)
+	if res.AbstainedWeight > 0 {
+		fmt.Printf("    Abstentions: weight %d\n", res.AbstainedWeight)
+	}
+	if !res.Valid {
+		fmt.Printf("    Invalid: The quorum of %d was not reached\n", res.Quorum)
+	}
+}
@@ -25,16 +25,76 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/FabianWe/sturavoting"
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 	log "github.com/sirupsen/logrus"
 )
 
+// Exit codes used by the program.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-config DIR] COMMAND [ARGS]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Options:")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", strings.TrimSpace(name+" "+commands[name].usage))
+	}
+}
+
+// findCommand returns the command for args, commands consist of either one
+// or two words. The remaining arguments are returned as well.
+func findCommand(args []string) (string, *command, []string) {
+	if len(args) >= 2 {
+		name := args[0] + " " + args[1]
+		if cmd, has := commands[name]; has {
+			return name, cmd, args[2:]
+		}
+	}
+	if len(args) >= 1 {
+		if cmd, has := commands[args[0]]; has {
+			return args[0], cmd, args[1:]
+		}
+	}
+	return "", nil, nil
+}
+
 func main() {
 	configDirPtr := flag.String("config", "./config", "Directory to store the configuration files.")
+	flag.Usage = usage
 	flag.Parse()
+	name, cmd, args := findCommand(flag.Args())
+	if cmd == nil {
+		if flag.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "Unknown command \"%s\"\n\n", strings.Join(flag.Args(), " "))
+		}
+		usage()
+		os.Exit(exitUsage)
+	}
+	if cmd.offline {
+		offlineContext := &sturavoting.VotingContext{Storage: sturavoting.NewMemoryStorage(),
+			Logger: log.StandardLogger()}
+		if err := cmd.run(offlineContext, args); err != nil {
+			exitWithError(name, cmd, err, offlineContext.Logger)
+		}
+		os.Exit(exitOK)
+	}
 	configDir, configDirParseErr := filepath.Abs(*configDirPtr)
 	if configDirParseErr != nil {
 		log.WithError(configDirParseErr).Fatal("Can't parse config dir path: ", configDir)
@@ -43,38 +103,22 @@ func main() {
 	if configErr != nil {
 		log.WithError(configErr).Fatal("Can't parse config file(s)")
 	}
-	// categories, catErr := sturavoting.ListCategories(appContext)
-	// if catErr != nil {
-	// 	log.Fatal(catErr)
-	// }
-	// for _, c := range categories {
-	// 	fmt.Println(c)
-	// }
-	// revs, revsErr := sturavoting.ListVotersRevision(appContext, 1)
-	// if revsErr != nil {
-	// 	log.Fatal(revsErr)
-	// }
-	// for _, r := range revs {
-	// 	fmt.Println(r)
-	// }
-	// f, openErr := os.Open("examples/voters.txt")
-	// if openErr != nil {
-	// 	log.Fatal(openErr)
-	// }
-	// defer f.Close()
-	// voters, votersParseErr := sturavoting.ParseVoters(f)
-	// if votersParseErr != nil {
-	// 	log.Fatal(votersParseErr)
-	// }
-	// votersInsertErr := sturavoting.InsertVoters(appContext, 1, voters)
-	// if votersInsertErr != nil {
-	// 	log.Fatal(votersInsertErr)
-	// }
-	votersList, votersGetErr := sturavoting.ListVoters(appContext, 1)
-	if votersGetErr != nil {
-		log.Fatal(votersGetErr)
+	if name != "migrate" && appContext.SchemaVersion < sturavoting.LatestSchemaVersion {
+		appContext.Logger.WithField("version", appContext.SchemaVersion).Fatal("Database schema is outdated, run \"migrate\" first")
 	}
-	for _, v := range votersList {
-		fmt.Println(v)
+	if err := cmd.run(appContext, args); err != nil {
+		exitWithError(name, cmd, err, appContext.Logger)
+	}
+	os.Exit(exitOK)
+}
+
+// exitWithError reports the error returned by cmd and exits with exitUsage
+// for usage errors and exitError otherwise.
+func exitWithError(name string, cmd *command, err error, logger *log.Logger) {
+	if usageErr, ok := err.(usageError); ok {
+		fmt.Fprintf(os.Stderr, "%s\n\nUsage: %s\n", usageErr.Error(), strings.TrimSpace(name+" "+cmd.usage))
+		os.Exit(exitUsage)
 	}
+	logger.WithError(err).Error("Command ", name, " failed")
+	os.Exit(exitError)
 }
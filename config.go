@@ -29,6 +29,7 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"net/http"
 	"os"
 	"path"
 	"time"
@@ -41,7 +42,7 @@ import (
 )
 
 type VotingContext struct {
-	DB                *sql.DB
+	Storage           Storage
 	ConfigDir         string
 	Store             sessions.Store
 	Logger            *logrus.Logger
@@ -51,6 +52,10 @@ type VotingContext struct {
 	Templates         map[string]*template.Template
 	SessionLifespan   time.Duration
 	Port              int
+	// SchemaVersion is the version of the database schema when the config
+	// was parsed, if it is smaller than LatestSchemaVersion the database must
+	// be migrated before it can be used.
+	SchemaVersion int
 }
 
 func (context *VotingContext) ReadOrCreateKeys() {
@@ -78,7 +83,25 @@ func (context *VotingContext) ReadOrCreateKeys() {
 		res = pairs
 	}
 	context.Keys = res
-	context.Store = sessions.NewCookieStore(res...)
+	store := sessions.NewCookieStore(res...)
+	// the session cookie is not sent with requests from other sites, the
+	// forms are also protected by a CSRF token
+	store.Options.SameSite = http.SameSiteStrictMode
+	context.Store = store
+}
+
+// UserIDByName returns the id of the user with the given name.
+func (context *VotingContext) UserIDByName(userName string) (uint64, error) {
+	users, err := context.UserHandler.ListUsers()
+	if err != nil {
+		return 0, err
+	}
+	for id, name := range users {
+		if name == userName {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("There is no user with name \"%s\"", userName)
 }
 
 func ReadKeyPairs(path string) ([][]byte, error) {
@@ -148,9 +171,17 @@ type dbInfo struct {
 	Port                         int
 }
 
+// sqliteInfo describes the SQLite database file, a relative path is
+// interpreted relative to the config directory.
+type sqliteInfo struct {
+	File string
+}
+
 type tomlConfig struct {
 	Port         int
+	Backend      string
 	DB           dbInfo       `toml:"mysql"`
+	SQLite       sqliteInfo   `toml:"sqlite"`
 	TimeSettings timeSettings `toml:"timers"`
 }
 
@@ -169,6 +200,12 @@ type timeSettings struct {
 	invalidKeyTimer duration `toml:"invalid-keys"`
 }
 
+// ParseConfig reads the file "conf" in configDir and connects to the
+// database. The backend entry selects the database, for "mysql" the
+// connection is configured in the [mysql] table, for "sqlite" the database
+// file is configured with file in the [sqlite] table.
+// It returns an error if the database schema is newer than
+// LatestSchemaVersion, but doesn't apply any migrations.
 func ParseConfig(configDir string) (*VotingContext, error) {
 	confPath := path.Join(configDir, "conf")
 	var conf tomlConfig
@@ -178,33 +215,16 @@ func ParseConfig(configDir string) (*VotingContext, error) {
 	if conf.Port == 0 {
 		conf.Port = 80
 	}
-	if conf.DB.User == "" {
-		conf.DB.User = "root"
-	}
-	if conf.DB.Port == 0 {
-		conf.DB.Port = 3306
-	}
-	if conf.DB.Host == "" {
-		conf.DB.Host = "localhost"
-	}
-	if conf.DB.DBName == "" {
-		conf.DB.DBName = "voting"
-	}
-	var confDBStr string
-
-	if conf.DB.Password == "" {
-		confDBStr = fmt.Sprintf("%s@tcp(%s:%d)/%s", conf.DB.User, conf.DB.Host, conf.DB.Port, conf.DB.DBName)
-	} else {
-		confDBStr = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", conf.DB.User, conf.DB.Password, conf.DB.Host, conf.DB.Port, conf.DB.DBName)
-	}
-
-	db, openErr := sql.Open("mysql", confDBStr)
+	db, dialect, openErr := openDB(configDir, &conf)
 	if openErr != nil {
 		return nil, openErr
 	}
 
-	if initErr := initDB(db); initErr != nil {
-		return nil, initErr
+	logger := logrus.New()
+	storage := NewSQLStorage(db, dialect, logger)
+	schemaVersion, schemaErr := storage.CheckSchema()
+	if schemaErr != nil {
+		return nil, schemaErr
 	}
 
 	var invalidKeyTimer, sessionLifespan time.Duration
@@ -222,14 +242,23 @@ func ParseConfig(configDir string) (*VotingContext, error) {
 	}
 
 	pwHandler := goauth.NewScryptHandler(nil)
-	userHandler := goauth.NewMySQLUserHandler(db, pwHandler)
-	sessionController := goauth.NewMySQLSessionController(db, "", "")
+	var userHandler goauth.UserHandler
+	var sessionController *goauth.SessionController
+	switch dialect {
+	case SQLiteDialect:
+		userHandler = goauth.NewSQLiteUserHandler(db, pwHandler)
+		sessionController = goauth.NewSQLiteSessionController(db, "", "")
+	default:
+		userHandler = goauth.NewMySQLUserHandler(db, pwHandler)
+		sessionController = goauth.NewMySQLSessionController(db, "", "")
+	}
 
-	res := &VotingContext{DB: db, ConfigDir: configDir,
-		Store: nil, Logger: logrus.New(), UserHandler: userHandler,
+	res := &VotingContext{Storage: storage, ConfigDir: configDir,
+		Store: nil, Logger: logger, UserHandler: userHandler,
 		SessionController: sessionController, Templates: make(map[string]*template.Template)}
 	res.SessionLifespan = sessionLifespan
 	res.Port = conf.Port
+	res.SchemaVersion = schemaVersion
 	res.ReadOrCreateKeys()
 	if err := userHandler.Init(); err != nil {
 		res.Logger.Fatal("Unable to connecto to database:", err)
@@ -247,3 +276,54 @@ func ParseConfig(configDir string) (*VotingContext, error) {
 	res.Logger.WithField("sleep-time", invalidKeyTimer).Info("Starting daemon to delete invalid keys")
 	return res, nil
 }
+
+// openDB opens the database selected by the backend entry in the config,
+// "mysql" (the default) or "sqlite".
+func openDB(configDir string, conf *tomlConfig) (*sql.DB, SQLDialect, error) {
+	switch conf.Backend {
+	case "", "mysql":
+		if conf.DB.User == "" {
+			conf.DB.User = "root"
+		}
+		if conf.DB.Port == 0 {
+			conf.DB.Port = 3306
+		}
+		if conf.DB.Host == "" {
+			conf.DB.Host = "localhost"
+		}
+		if conf.DB.DBName == "" {
+			conf.DB.DBName = "voting"
+		}
+		var confDBStr string
+
+		if conf.DB.Password == "" {
+			confDBStr = fmt.Sprintf("%s@tcp(%s:%d)/%s", conf.DB.User, conf.DB.Host, conf.DB.Port, conf.DB.DBName)
+		} else {
+			confDBStr = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", conf.DB.User, conf.DB.Password, conf.DB.Host, conf.DB.Port, conf.DB.DBName)
+		}
+		db, err := sql.Open("mysql", confDBStr)
+		return db, MySQLDialect, err
+	case "sqlite":
+		file := conf.SQLite.File
+		if file == "" {
+			file = "voting.db"
+		}
+		if !path.IsAbs(file) {
+			file = path.Join(configDir, file)
+		}
+		db, err := OpenSQLite(file)
+		return db, SQLiteDialect, err
+	default:
+		return nil, MySQLDialect, fmt.Errorf("Unknown database backend \"%s\", expected \"mysql\" or \"sqlite\"", conf.Backend)
+	}
+}
+
+// OpenSQLite opens the SQLite database stored in file, the file is created
+// if it doesn't exist.
+// Foreign keys are enabled for each connection (SQLite disables them by
+// default) and a busy timeout is set s.t. concurrent writes wait instead of
+// failing immediately.
+// The sqlite3 driver must be registered by the caller.
+func OpenSQLite(file string) (*sql.DB, error) {
+	return sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=1&_busy_timeout=5000", file))
+}
@@ -0,0 +1,237 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The JSON and YAML encodings use the field names from the struct tags of
+// the types. When decoding votings percent_required defaults to -1.0 (no
+// value set) as in ParseVotingCollection.
+
+// UnmarshalJSON decodes the voting, percent_required defaults to -1.0.
+func (voting *MedianVoting) UnmarshalJSON(data []byte) error {
+	type plain MedianVoting
+	decoded := plain{PercentRequired: -1.0}
+	if err := decodeJSON(bytes.NewReader(data), &decoded); err != nil {
+		return err
+	}
+	*voting = MedianVoting(decoded)
+	return nil
+}
+
+// UnmarshalYAML decodes the voting, percent_required defaults to -1.0.
+func (voting *MedianVoting) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain MedianVoting
+	decoded := plain{PercentRequired: -1.0}
+	if err := unmarshal(&decoded); err != nil {
+		return err
+	}
+	*voting = MedianVoting(decoded)
+	return nil
+}
+
+// UnmarshalJSON decodes the voting, percent_required defaults to -1.0.
+func (voting *SchulzeVoting) UnmarshalJSON(data []byte) error {
+	type plain SchulzeVoting
+	decoded := plain{PercentRequired: -1.0}
+	if err := decodeJSON(bytes.NewReader(data), &decoded); err != nil {
+		return err
+	}
+	*voting = SchulzeVoting(decoded)
+	return nil
+}
+
+// UnmarshalYAML decodes the voting, percent_required defaults to -1.0.
+func (voting *SchulzeVoting) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain SchulzeVoting
+	decoded := plain{PercentRequired: -1.0}
+	if err := unmarshal(&decoded); err != nil {
+		return err
+	}
+	*voting = SchulzeVoting(decoded)
+	return nil
+}
+
+// ValidateVoters checks the constraints ParseVoters enforces: Names must not
+// be empty and at most 150 characters long. In addition the names must be
+// unique.
+func ValidateVoters(voters []*Voter) error {
+	names := make(map[string]bool, len(voters))
+	for i, voter := range voters {
+		if voter == nil {
+			return fmt.Errorf("Voter %d is empty", i+1)
+		}
+		if err := validateVotingsString(voter.Name); err != nil {
+			return fmt.Errorf("Voter %d: %s", i+1, err.Error())
+		}
+		if names[voter.Name] {
+			return fmt.Errorf("Voter \"%s\" exists more than once", voter.Name)
+		}
+		names[voter.Name] = true
+	}
+	return nil
+}
+
+// ValidateVotingCollection checks the constraints ParseVotingCollection
+// enforces: All names and options must not be empty and at most 150
+// characters long, the max value of a median voting must not be negative,
+// each schulze voting must have at least one option, the status quo must
+// be empty or one of the options and percent_required must be -1.0 (not set)
+// or between 0 and 1.
+// In addition the options of a schulze voting must be unique.
+// Nil slices are replaced by empty slices, as created by
+// ParseVotingCollection.
+func ValidateVotingCollection(collection *VotingCollection) error {
+	if err := validateVotingsString(collection.Name); err != nil {
+		return fmt.Errorf("Collection: %s", err.Error())
+	}
+	if collection.Groups == nil {
+		collection.Groups = make([]*VotingGroup, 0)
+	}
+	for i, group := range collection.Groups {
+		if group == nil {
+			return fmt.Errorf("Group %d is empty", i+1)
+		}
+		if err := validateVotingsString(group.Name); err != nil {
+			return fmt.Errorf("Group %d: %s", i+1, err.Error())
+		}
+		if group.MedianVotings == nil {
+			group.MedianVotings = make([]*MedianVoting, 0)
+		}
+		if group.SchulzeVotings == nil {
+			group.SchulzeVotings = make([]*SchulzeVoting, 0)
+		}
+		for j, voting := range group.MedianVotings {
+			if voting == nil {
+				return fmt.Errorf("Group \"%s\": Median voting %d is empty", group.Name, j+1)
+			}
+			if err := validateVotingsString(voting.Name); err != nil {
+				return fmt.Errorf("Group \"%s\": Median voting %d: %s", group.Name, j+1, err.Error())
+			}
+			if voting.MaxValue < 0 {
+				return fmt.Errorf("Voting \"%s\": Value must not be negative", voting.Name)
+			}
+			if err := checkPercentWritable(voting.Name, voting.PercentRequired); err != nil {
+				return err
+			}
+		}
+		for j, voting := range group.SchulzeVotings {
+			if voting == nil {
+				return fmt.Errorf("Group \"%s\": Schulze voting %d is empty", group.Name, j+1)
+			}
+			if err := validateVotingsString(voting.Name); err != nil {
+				return fmt.Errorf("Group \"%s\": Schulze voting %d: %s", group.Name, j+1, err.Error())
+			}
+			if len(voting.Options) == 0 {
+				return fmt.Errorf("Voting \"%s\": At least one option is required", voting.Name)
+			}
+			if err := checkPercentWritable(voting.Name, voting.PercentRequired); err != nil {
+				return err
+			}
+			options := make(map[string]bool, len(voting.Options))
+			for _, option := range voting.Options {
+				if err := validateVotingsString(option); err != nil {
+					return fmt.Errorf("Voting \"%s\": Option: %s", voting.Name, err.Error())
+				}
+				if options[option] {
+					return fmt.Errorf("Voting \"%s\": Option \"%s\" exists more than once", voting.Name, option)
+				}
+				options[option] = true
+			}
+			if voting.StatusQuoIndex() < 0 {
+				return fmt.Errorf("Voting \"%s\": Status quo \"%s\" is not an option", voting.Name, voting.StatusQuo)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeJSON decodes r into v, unknown fields are not allowed.
+func decodeJSON(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// decodeYAML decodes r into v, unknown fields are not allowed.
+func decodeYAML(r io.Reader, v interface{}) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(data, v)
+}
+
+// DecodeVotersJSON decodes a JSON list of voters and validates it with
+// ValidateVoters.
+func DecodeVotersJSON(r io.Reader) ([]*Voter, error) {
+	return decodeVoters(r, decodeJSON)
+}
+
+// DecodeVotersYAML decodes a YAML list of voters and validates it with
+// ValidateVoters.
+func DecodeVotersYAML(r io.Reader) ([]*Voter, error) {
+	return decodeVoters(r, decodeYAML)
+}
+
+func decodeVoters(r io.Reader, decode func(io.Reader, interface{}) error) ([]*Voter, error) {
+	res := make([]*Voter, 0)
+	if err := decode(r, &res); err != nil {
+		return nil, err
+	}
+	if err := ValidateVoters(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DecodeVotingCollectionJSON decodes a JSON collection and validates it with
+// ValidateVotingCollection.
+func DecodeVotingCollectionJSON(r io.Reader) (*VotingCollection, error) {
+	return decodeVotingCollection(r, decodeJSON)
+}
+
+// DecodeVotingCollectionYAML decodes a YAML collection and validates it with
+// ValidateVotingCollection.
+func DecodeVotingCollectionYAML(r io.Reader) (*VotingCollection, error) {
+	return decodeVotingCollection(r, decodeYAML)
+}
+
+func decodeVotingCollection(r io.Reader, decode func(io.Reader, interface{}) error) (*VotingCollection, error) {
+	res := &VotingCollection{}
+	if err := decode(r, res); err != nil {
+		return nil, err
+	}
+	if err := ValidateVotingCollection(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,125 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func parseExampleCollection(t *testing.T) *VotingCollection {
+	f, err := os.Open("examples/stura-9.5.17.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	collection, err := ParseVotingCollection(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return collection
+}
+
+func TestCollectionJSONRoundTrip(t *testing.T) {
+	collection := parseExampleCollection(t)
+	data, err := json.Marshal(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeVotingCollectionJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, collection) {
+		t.Errorf("Expected\n%s\nafter round trip, got\n%s", collection, decoded)
+	}
+}
+
+func TestCollectionYAMLRoundTrip(t *testing.T) {
+	collection := parseExampleCollection(t)
+	data, err := yaml.Marshal(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeVotingCollectionYAML(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, collection) {
+		t.Errorf("Expected\n%s\nafter round trip, got\n%s", collection, decoded)
+	}
+}
+
+func TestDecodeVotingCollectionJSON(t *testing.T) {
+	valid := `{"name": "StuRa", "date": "2017-05-09T00:00:00Z", "groups": [{"name": "TOP 1",
+		"median_votings": [{"name": "Exkursion", "max_value": 108660}],
+		"schulze_votings": [{"name": "Wahl", "options": ["A", "Nein"], "percent_required": 0.5}]}]}`
+	collection, err := DecodeVotingCollectionJSON(strings.NewReader(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := collection.Groups[0]
+	if group.MedianVotings[0].PercentRequired != -1.0 {
+		t.Errorf("Expected PercentRequired -1 if not set, got %f", group.MedianVotings[0].PercentRequired)
+	}
+	if group.SchulzeVotings[0].PercentRequired != 0.5 {
+		t.Errorf("Expected PercentRequired 0.5, got %f", group.SchulzeVotings[0].PercentRequired)
+	}
+	invalid := []string{
+		`{"name": "", "date": "2017-05-09T00:00:00Z"}`,
+		`{"name": "StuRa", "groups": [{"name": "` + strings.Repeat("a", 151) + `"}]}`,
+		`{"name": "StuRa", "groups": [{"name": "TOP", "schulze_votings": [{"name": "Wahl", "options": ["A", "A"]}]}]}`,
+		`{"name": "StuRa", "groups": [{"name": "TOP", "schulze_votings": [{"name": "Wahl", "options": []}]}]}`,
+		`{"name": "StuRa", "groups": [{"name": "TOP", "median_votings": [{"name": "Geld", "max_value": -1}]}]}`,
+		`{"name": "StuRa", "groups": [{"name": "TOP", "median_votings": [{"name": "Geld", "maxvalue": 1}]}]}`,
+		`{"name": "StuRa", "titel": "Sitzung"}`,
+		`{"name": "StuRa", "groups": [{"name": "TOP", "schulze_votings": [{"name": "Wahl", "options": ["A", "Nein"], "status_quo": "No"}]}]}`,
+		`{"name": "StuRa", "groups": [{"name": "TOP", "median_votings": [{"name": "Geld", "percent_required": 1.5}]}]}`,
+	}
+	for _, str := range invalid {
+		if _, err := DecodeVotingCollectionJSON(strings.NewReader(str)); err == nil {
+			t.Errorf("Expected error when decoding %s", str)
+		}
+	}
+}
+
+func TestDecodeVotersJSON(t *testing.T) {
+	voters, err := DecodeVotersJSON(strings.NewReader(`[{"name": "Alice", "weight": 2}, {"name": "Bob", "weight": 1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(voters, []*Voter{NewVoter("Alice", 2), NewVoter("Bob", 1)}) {
+		t.Errorf("Expected Alice: 2 and Bob: 1, got %v", voters)
+	}
+	for _, str := range []string{`[{"name": "", "weight": 1}]`, `[{"name": "A", "weight": 1}, {"name": "A", "weight": 2}]`, `{"name": "A"}`} {
+		if _, err := DecodeVotersJSON(strings.NewReader(str)); err == nil {
+			t.Errorf("Expected error when decoding %s", str)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MajorityError is returned when evaluating a voting without a valid
+// majority, see votingMajority.
+type MajorityError struct {
+	Voting  string
+	message string
+}
+
+func (err *MajorityError) Error() string {
+	return fmt.Sprintf("Voting \"%s\": %s", err.Voting, err.message)
+}
+
+// votingMajority returns the majority for the PercentRequired of a voting.
+// If percentRequired is -1.0 (not set) it returns the DefaultMajority of the
+// options and a MajorityError if there is none. It also returns a
+// MajorityError if percentRequired is not a valid majority.
+func votingMajority(name string, percentRequired float64, options *EvaluationOptions) (Majority, error) {
+	if percentRequired == -1.0 {
+		if options == nil || options.DefaultMajority == nil {
+			return -1.0, &MajorityError{Voting: name, message: "No majority set and no default majority given"}
+		}
+		percentRequired = float64(*options.DefaultMajority)
+	}
+	majority, err := NewMajority(percentRequired)
+	if err != nil {
+		return -1.0, &MajorityError{Voting: name, message: err.Error()}
+	}
+	return majority, nil
+}
+
+// EvaluationOptions configures the basis of the majorities and the quorum
+// when evaluating votings.
+type EvaluationOptions struct {
+	// Basis is the basis of the majorities.
+	Basis MajorityBasis
+	// Present is the weight of the voters present, it is required for
+	// PresentBasis.
+	Present int
+	// Quorum is the fraction of the weight of all eligible voters that must
+	// at least be cast for a voting to be valid, 0 if there is no quorum.
+	// Other than a majority a quorum of 1 is valid.
+	Quorum float64
+	// DefaultMajority is the majority of votings without a majority, if it
+	// is nil evaluating such a voting returns an error.
+	DefaultMajority *Majority
+	// TieBreaker configures how ties in schulze votings are broken, nil
+	// reports ties.
+	TieBreaker *TieBreaker
+}
+
+// DefaultEvaluationOptions returns the options used if no options are
+// given: Majorities are computed from the votes cast, there is no quorum,
+// there is no default majority and ties are reported.
+func DefaultEvaluationOptions() *EvaluationOptions {
+	return &EvaluationOptions{Basis: CastBasis}
+}
+
+// AbsoluteMajorityOptions returns options for an absolute majority:
+// Majorities are computed from the weight of all eligible voters and
+// votings without a majority require SimpleMajority. Votings with a
+// majority require that majority of all eligible voters.
+func AbsoluteMajorityOptions() *EvaluationOptions {
+	majority := SimpleMajority
+	return &EvaluationOptions{Basis: EligibleBasis, DefaultMajority: &majority}
+}
+
+// EvaluationOptionsError is returned if the evaluation options are invalid,
+// see EvaluationOptions.Validate and EvaluationOptions.Reference.
+type EvaluationOptionsError struct {
+	message string
+}
+
+func (err *EvaluationOptionsError) Error() string {
+	return err.message
+}
+
+func newEvaluationOptionsError(format string, a ...interface{}) *EvaluationOptionsError {
+	return &EvaluationOptionsError{message: fmt.Sprintf(format, a...)}
+}
+
+// Validate returns an EvaluationOptionsError if the options are invalid,
+// the weight of the voters present is compared to the weight of all
+// eligible voters only in Reference.
+func (options *EvaluationOptions) Validate() error {
+	// also rejects NaN
+	if !(options.Quorum >= 0.0 && options.Quorum <= 1.0) {
+		return newEvaluationOptionsError("Invalid quorum %v: Must be between 0 and 1", options.Quorum)
+	}
+	if options.Present < 0 {
+		return newEvaluationOptionsError("The weight of the voters present must not be negative, got %d", options.Present)
+	}
+	switch options.Basis {
+	case CastBasis, EligibleBasis:
+	case PresentBasis:
+		if options.Present == 0 {
+			return newEvaluationOptionsError("The weight of the voters present is required for majorities of the voters present")
+		}
+	default:
+		return newEvaluationOptionsError("Invalid majority basis %s", options.Basis)
+	}
+	if options.DefaultMajority != nil {
+		if err := options.DefaultMajority.Validate(); err != nil {
+			return newEvaluationOptionsError("Invalid default majority: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// Reference returns the reference for the options, eligible is the weight
+// of all eligible voters (the voters of the revision the votings are linked
+// to). It returns an EvaluationOptionsError if the options are invalid.
+// A nil options is the same as DefaultEvaluationOptions.
+func (options *EvaluationOptions) Reference(eligible int) (*Reference, error) {
+	if options == nil {
+		options = DefaultEvaluationOptions()
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	// the quorum is the weight rounded up, computed as in
+	// Majority.VotesRequired
+	numerator, denominator := Fraction(options.Quorum)
+	res := &Reference{Basis: options.Basis,
+		Quorum: int((int64(eligible)*numerator + denominator - 1) / denominator)}
+	switch options.Basis {
+	case CastBasis:
+	case PresentBasis:
+		if options.Present > eligible {
+			return nil, newEvaluationOptionsError("The weight of the voters present (%d) is greater than the weight of all eligible voters (%d)", options.Present, eligible)
+		}
+		res.Weight = options.Present
+	case EligibleBasis:
+		res.Weight = eligible
+	}
+	return res, nil
+}
+
+// tieBreaker returns the TieBreaker of the options, options may be nil.
+func (options *EvaluationOptions) tieBreaker() *TieBreaker {
+	if options == nil {
+		return nil
+	}
+	return options.TieBreaker
+}
+
+// VotersWeight returns the sum of the weights of all voters.
+func VotersWeight(voters []*Voter) int {
+	res := 0
+	for _, voter := range voters {
+		res += voter.Weight
+	}
+	return res
+}
+
+// revisionReference returns the eligible voters, i.e. the voters in the
+// revision with id revisionID, and the reference for the options.
+func revisionReference(storage Storage, revisionID uint, options *EvaluationOptions) ([]*Voter, *Reference, error) {
+	voters, err := storage.ListVoters(revisionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	reference, err := options.Reference(VotersWeight(voters))
+	if err != nil {
+		return nil, nil, err
+	}
+	return voters, reference, nil
+}
+
+// medianAbstentions returns the names of all voters that abstained.
+func medianAbstentions(votes []*MedianVote) []string {
+	res := make([]string, 0)
+	for _, vote := range votes {
+		if vote.Abstain {
+			res = append(res, vote.Voter)
+		}
+	}
+	return res
+}
+
+// medianNotVoted returns the names of all voters that didn't vote, voters
+// that abstained voted.
+func medianNotVoted(voters []*Voter, votes []*MedianVote) []string {
+	voted := make(map[string]bool, len(votes))
+	for _, vote := range votes {
+		voted[vote.Voter] = true
+	}
+	res := make([]string, 0)
+	for _, voter := range voters {
+		if !voted[voter.Name] {
+			res = append(res, voter.Name)
+		}
+	}
+	return res
+}
+
+// MedianVotingResult is the result of evaluating a median voting.
+type MedianVotingResult struct {
+	// Voting is the voting that was evaluated.
+	Voting *MedianVoting `json:"voting" yaml:"voting"`
+	// PercentRequired is the percentage that was used for the evaluation.
+	PercentRequired float64 `json:"percent_required" yaml:"percent_required"`
+	// NumVotes is the number of votes that were cast.
+	NumVotes int `json:"num_votes" yaml:"num_votes"`
+	// NotVoted contains the names of all eligible voters that didn't vote,
+	// i.e. they were absent.
+	NotVoted []string `json:"not_voted" yaml:"not_voted"`
+	// Abstentions contains the names of all voters that abstained, they
+	// count for the quorum (see MajorityResult.AbstainedWeight).
+	Abstentions   []string `json:"abstentions" yaml:"abstentions"`
+	*MedianResult `json:"result" yaml:"result"`
+}
+
+// SchulzeVotingResult is the result of evaluating a schulze voting.
+type SchulzeVotingResult struct {
+	// Voting is the voting that was evaluated.
+	Voting *SchulzeVoting `json:"voting" yaml:"voting"`
+	// PercentRequired is the percentage that was used for the evaluation.
+	PercentRequired float64 `json:"percent_required" yaml:"percent_required"`
+	// NumVotes is the number of votes that were cast.
+	NumVotes int `json:"num_votes" yaml:"num_votes"`
+	// RankedOptions is the same as Ranked in SchulzeRes but contains the
+	// names of the options instead of their indices.
+	RankedOptions [][]string `json:"ranked_options" yaml:"ranked_options"`
+	*SchulzeRes   `json:"result" yaml:"result"`
+}
+
+// EvaluateMedianVoting loads the median voting with the given id and all
+// votes for it and evaluates the voting with EvaluateMedian.
+// The eligible voters are the voters of the revision the voting is linked
+// to, options may be nil (see EvaluationOptions.Reference).
+func EvaluateMedianVoting(storage Storage, votingID uint, options *EvaluationOptions) (*MedianVotingResult, error) {
+	voting, err := storage.GetMedianVoting(votingID)
+	if err != nil {
+		return nil, err
+	}
+	revisionID, err := storage.GetMedianVotingRevision(votingID)
+	if err != nil {
+		return nil, err
+	}
+	voters, reference, err := revisionReference(storage, revisionID, options)
+	if err != nil {
+		return nil, err
+	}
+	votes, err := storage.GetMedianVotes(votingID)
+	if err != nil {
+		return nil, err
+	}
+	majority, err := votingMajority(voting.Name, voting.PercentRequired, options)
+	if err != nil {
+		return nil, err
+	}
+	res, err := EvaluateMedian(votes, majority, reference)
+	if err != nil {
+		return nil, err
+	}
+	return &MedianVotingResult{Voting: voting, PercentRequired: float64(majority),
+		NumVotes: len(votes), NotVoted: medianNotVoted(voters, votes),
+		Abstentions:  medianAbstentions(votes),
+		MedianResult: res}, nil
+}
+
+// EvaluateSchulzeVoting loads the schulze voting with the given id and all
+// votes for it and evaluates the voting with EvaluateSchulze.
+// The eligible voters are determined as in EvaluateMedianVoting.
+func EvaluateSchulzeVoting(storage Storage, votingID uint, options *EvaluationOptions) (*SchulzeVotingResult, error) {
+	voting, err := storage.GetSchulzeVoting(votingID)
+	if err != nil {
+		return nil, err
+	}
+	revisionID, err := storage.GetSchulzeVotingRevision(votingID)
+	if err != nil {
+		return nil, err
+	}
+	_, reference, err := revisionReference(storage, revisionID, options)
+	if err != nil {
+		return nil, err
+	}
+	votes, err := storage.GetSchulzeVotes(votingID)
+	if err != nil {
+		return nil, err
+	}
+	majority, err := votingMajority(voting.Name, voting.PercentRequired, options)
+	if err != nil {
+		return nil, err
+	}
+	res, err := EvaluateSchulze(votes, len(voting.Options), voting.StatusQuoIndex(), majority, reference, options.tieBreaker())
+	if err != nil {
+		return nil, err
+	}
+	return &SchulzeVotingResult{Voting: voting, PercentRequired: float64(majority),
+		NumVotes: len(votes), RankedOptions: RankedOptionNames(voting, res.Ranked),
+		SchulzeRes: res}, nil
+}
+
+// RankedOptionNames translates the option indices in ranked (as returned by
+// EvaluateSchulze) to the option names of the voting.
+func RankedOptionNames(voting *SchulzeVoting, ranked [][]int) [][]string {
+	res := make([][]string, len(ranked))
+	for i, group := range ranked {
+		names := make([]string, len(group))
+		for j, option := range group {
+			names[j] = voting.Options[option]
+		}
+		res[i] = names
+	}
+	return res
+}
+
+// GroupResult contains the results of all votings in a group.
+type GroupResult struct {
+	Group          *VotingGroup           `json:"group" yaml:"group"`
+	MedianResults  []*MedianVotingResult  `json:"median_results" yaml:"median_results"`
+	SchulzeResults []*SchulzeVotingResult `json:"schulze_results" yaml:"schulze_results"`
+}
+
+// VotingResult is an entry in the ordered list of results of a group,
+// exactly one of Median and Schulze is not nil.
+type VotingResult struct {
+	Median  *MedianVotingResult
+	Schulze *SchulzeVotingResult
+}
+
+// Results returns the median and schulze results ordered by the position of
+// their votings, as in VotingGroup.Votings.
+func (groupRes *GroupResult) Results() []*VotingResult {
+	res := make([]*VotingResult, 0, len(groupRes.MedianResults)+len(groupRes.SchulzeResults))
+	for _, result := range groupRes.MedianResults {
+		res = append(res, &VotingResult{Median: result})
+	}
+	for _, result := range groupRes.SchulzeResults {
+		res = append(res, &VotingResult{Schulze: result})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].position() < res[j].position()
+	})
+	return res
+}
+
+func (result *VotingResult) position() int {
+	if result.Median != nil {
+		return result.Median.Voting.Position
+	}
+	return result.Schulze.Voting.Position
+}
+
+// EvaluateBallots evaluates all votings in the collection with the given
+// ballots, no storage is required.
+// voters are the eligible voters, options may be nil (see
+// EvaluationOptions.Reference).
+func EvaluateBallots(collection *VotingCollection, voters []*Voter, ballots *Ballots, options *EvaluationOptions) ([]*GroupResult, error) {
+	reference, err := options.Reference(VotersWeight(voters))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*GroupResult, len(collection.Groups))
+	for i, group := range collection.Groups {
+		groupRes := &GroupResult{Group: group,
+			MedianResults:  make([]*MedianVotingResult, len(group.MedianVotings)),
+			SchulzeResults: make([]*SchulzeVotingResult, len(group.SchulzeVotings))}
+		for j, voting := range group.MedianVotings {
+			votes := ballots.MedianVotes(voting)
+			majority, err := votingMajority(voting.Name, voting.PercentRequired, options)
+			if err != nil {
+				return nil, err
+			}
+			medianRes, err := EvaluateMedian(votes, majority, reference)
+			if err != nil {
+				return nil, err
+			}
+			groupRes.MedianResults[j] = &MedianVotingResult{Voting: voting,
+				PercentRequired: float64(majority), NumVotes: len(votes),
+				NotVoted:     medianNotVoted(voters, votes),
+				Abstentions:  medianAbstentions(votes),
+				MedianResult: medianRes}
+		}
+		for j, voting := range group.SchulzeVotings {
+			votes := ballots.SchulzeVotes(voting)
+			majority, err := votingMajority(voting.Name, voting.PercentRequired, options)
+			if err != nil {
+				return nil, err
+			}
+			schulzeRes, err := EvaluateSchulze(votes, len(voting.Options), voting.StatusQuoIndex(), majority, reference, options.tieBreaker())
+			if err != nil {
+				return nil, err
+			}
+			groupRes.SchulzeResults[j] = &SchulzeVotingResult{Voting: voting,
+				PercentRequired: float64(majority), NumVotes: len(votes),
+				RankedOptions: RankedOptionNames(voting, schulzeRes.Ranked),
+				SchulzeRes:    schulzeRes}
+		}
+		res[i] = groupRes
+	}
+	return res, nil
+}
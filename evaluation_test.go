@@ -0,0 +1,198 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestEvaluateBallotsMajority(t *testing.T) {
+	median := &MedianVoting{Name: "Geld", MaxValue: 100, PercentRequired: -1.0}
+	schulze := &SchulzeVoting{Name: "Satzung", Options: []string{"Ja", "Nein"}, PercentRequired: 2.0 / 3.0, Position: 1}
+	collection := &VotingCollection{Name: "StuRa", Groups: []*VotingGroup{&VotingGroup{Name: "TOP 1",
+		MedianVotings: []*MedianVoting{median}, SchulzeVotings: []*SchulzeVoting{schulze}}}}
+	ballots := &Ballots{Median: make([]*MedianBallot, 0), Schulze: make([]*SchulzeBallot, 0)}
+	// the median voting has no majority and there is no default
+	if _, err := EvaluateBallots(collection, nil, ballots, nil); err == nil {
+		t.Error("Expected error for a voting without majority and no default majority")
+	} else if majorityErr, ok := err.(*MajorityError); !ok || majorityErr.Voting != "Geld" {
+		t.Errorf("Expected a MajorityError for voting \"Geld\", got %v", err)
+	}
+	defaultMajority := SimpleMajority
+	options := &EvaluationOptions{DefaultMajority: &defaultMajority}
+	results, err := EvaluateBallots(collection, nil, ballots, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if percent := results[0].MedianResults[0].PercentRequired; percent != float64(SimpleMajority) {
+		t.Errorf("Expected default majority for a voting without majority, got %v", percent)
+	}
+	if percent := results[0].SchulzeResults[0].PercentRequired; percent != float64(TwoThirdsMajority) {
+		t.Errorf("Expected majority 2/3, got %v", percent)
+	}
+	median.PercentRequired = 1.5
+	if _, err := EvaluateBallots(collection, nil, ballots, options); err == nil {
+		t.Error("Expected error for majority 1.5")
+	}
+}
+
+func TestEvaluationOptionsReference(t *testing.T) {
+	reference, err := (&EvaluationOptions{Basis: EligibleBasis, Quorum: 0.5}).Reference(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reference.Weight != 9 || reference.Quorum != 5 {
+		t.Errorf("Expected weight 9 and quorum 5, got %+v", reference)
+	}
+	reference, err = (*EvaluationOptions)(nil).Reference(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reference.Basis != CastBasis || reference.Quorum != 0 {
+		t.Errorf("Expected cast basis without quorum for nil options, got %+v", reference)
+	}
+	// everyone must vote
+	if reference, err = (&EvaluationOptions{Quorum: 1.0}).Reference(9); err != nil || reference.Quorum != 9 {
+		t.Errorf("Expected quorum 9 for quorum 1, got %+v (error %v)", reference, err)
+	}
+	absolute, err := AbsoluteMajorityOptions().Reference(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if absolute.Basis != EligibleBasis || absolute.Weight != 9 {
+		t.Errorf("Expected the eligible weight 9 for an absolute majority, got %+v", absolute)
+	}
+	invalid := []*EvaluationOptions{
+		&EvaluationOptions{Basis: PresentBasis},
+		&EvaluationOptions{Basis: PresentBasis, Present: 10},
+		&EvaluationOptions{Basis: CastBasis, Quorum: 1.5},
+	}
+	for _, options := range invalid {
+		if _, err := options.Reference(9); err == nil {
+			t.Errorf("Expected error for options %+v", options)
+		} else if _, ok := err.(*EvaluationOptionsError); !ok {
+			t.Errorf("Expected an EvaluationOptionsError for options %+v, got %v", options, err)
+		}
+	}
+	invalidMajority := Majority(1.0)
+	invalid = []*EvaluationOptions{
+		&EvaluationOptions{Basis: PresentBasis},
+		&EvaluationOptions{Basis: CastBasis, Present: -1},
+		&EvaluationOptions{Basis: MajorityBasis(42)},
+		&EvaluationOptions{DefaultMajority: &invalidMajority},
+	}
+	for _, options := range invalid {
+		if err := options.Validate(); err == nil {
+			t.Errorf("Expected error when validating options %+v", options)
+		}
+	}
+	// the weight present is only compared to the eligible weight in Reference
+	if err := (&EvaluationOptions{Basis: PresentBasis, Present: 10}).Validate(); err != nil {
+		t.Errorf("Expected no error when validating the weight present, got %v", err)
+	}
+}
+
+func TestEvaluateBallotsNotVoted(t *testing.T) {
+	median := &MedianVoting{Name: "Geld", MaxValue: 100, PercentRequired: 0.5}
+	collection := &VotingCollection{Name: "StuRa", Groups: []*VotingGroup{&VotingGroup{Name: "TOP 1",
+		MedianVotings: []*MedianVoting{median}}}}
+	alice := &Voter{Name: "Alice", Weight: 1}
+	bob := &Voter{Name: "Bob", Weight: 2}
+	carol := &Voter{Name: "Carol", Weight: 1}
+	ballots := &Ballots{Median: []*MedianBallot{&MedianBallot{Voter: alice, Voting: median, Value: 50},
+		&MedianBallot{Voter: carol, Voting: median, Abstain: true}},
+		Schulze: make([]*SchulzeBallot, 0)}
+	results, err := EvaluateBallots(collection, []*Voter{alice, bob, carol}, ballots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := results[0].MedianResults[0]
+	if len(res.NotVoted) != 1 || res.NotVoted[0] != "Bob" {
+		t.Errorf("Expected Bob to not vote, got %v", res.NotVoted)
+	}
+	if len(res.Abstentions) != 1 || res.Abstentions[0] != "Carol" {
+		t.Errorf("Expected abstention of Carol, got %v", res.Abstentions)
+	}
+	if len(res.Steps) != 1 || res.Steps[0].Value != 50 || !res.Steps[0].Majority {
+		t.Errorf("Expected a single step for 50 with majority, got %v", res.Steps)
+	}
+}
+
+// TestEvaluateExamples evaluates the ballots in the examples directory as
+// the evaluate command does.
+func TestEvaluateExamples(t *testing.T) {
+	parse := func(name string, parser func(f *os.File) error) {
+		f, err := os.Open(path.Join("examples", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err = parser(f); err != nil {
+			t.Fatalf("Error parsing %s: %v", name, err)
+		}
+	}
+	var voters []*Voter
+	var collection *VotingCollection
+	var ballots *Ballots
+	parse("voters.txt", func(f *os.File) (err error) {
+		voters, err = ParseVoters(f)
+		return
+	})
+	parse("stura-9.5.17.txt", func(f *os.File) (err error) {
+		collection, err = ParseVotingCollection(f)
+		return
+	})
+	parse("ballots.txt", func(f *os.File) (err error) {
+		ballots, err = ParseBallots(f, voters, collection)
+		return
+	})
+	defaultMajority := SimpleMajority
+	results, err := EvaluateBallots(collection, voters, ballots, &EvaluationOptions{DefaultMajority: &defaultMajority})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(collection.Groups) {
+		t.Fatalf("Expected a result for each of the %d groups, got %d", len(collection.Groups), len(results))
+	}
+	for i, groupRes := range results {
+		group := collection.Groups[i]
+		if len(groupRes.MedianResults) != len(group.MedianVotings) || len(groupRes.SchulzeResults) != len(group.SchulzeVotings) {
+			t.Errorf("Expected a result for each voting in group %s", group.Name)
+		}
+	}
+	distribution := results[0].SchulzeResults[0]
+	if distribution.NumVotes != 2 || !compareSlices(distribution.Ranked, [][]int{[]int{0}, []int{1}, []int{2}}) {
+		t.Errorf("Expected two votes and the ranking [[0] [1] [2]] for \"%s\", got %d votes and %v",
+			distribution.Voting.Name, distribution.NumVotes, distribution.Ranked)
+	}
+	library := results[1].MedianResults[1]
+	if library.NumVotes != 2 || library.Value != 80000 {
+		t.Errorf("Expected two votes and the value 800.00 for \"%s\", got %d votes and %d",
+			library.Voting.Name, library.NumVotes, library.Value)
+	}
+	if notVoted := results[1].MedianResults[0].NotVoted; len(notVoted) != 2 {
+		t.Errorf("Expected both voters to not vote for \"%s\", got %v", results[1].MedianResults[0].Voting.Name, notVoted)
+	}
+}
@@ -0,0 +1,658 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// memoryMedianVote is a median vote stored in a MemoryStorage.
+type memoryMedianVote struct {
+	voterID uint
+	value   int
+	abstain bool
+}
+
+// userRevision is the key for the links between users and voters, a user is
+// linked to at most one voter in each revision.
+type userRevision struct {
+	userID     uint64
+	revisionID uint
+}
+
+// MemoryStorage is a Storage that keeps all data in memory, it is safe for
+// concurrent use.
+// It enforces the same constraints as the database tables used by
+// SQLStorage (unique names, existing references), ids are unique among all
+// elements in the storage.
+type MemoryStorage struct {
+	mutex  sync.RWMutex
+	lastID uint
+
+	categories  []*Category
+	revisions   []*VotersRevision
+	voters      map[uint]*Voter
+	collections []*VotingCollection
+	// maps the ids of the votings to the votings and the voters revision of
+	// the collection that contains them
+	medianVotings   map[uint]*MedianVoting
+	schulzeVotings  map[uint]*SchulzeVoting
+	votingRevisions map[uint]uint
+	medianVotes     map[uint][]*memoryMedianVote
+	schulzeVotes    map[uint]map[uint][]int
+	userVoters      map[userRevision]uint
+	admins          map[uint64]bool
+}
+
+// NewMemoryStorage returns a new empty storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		categories:      make([]*Category, 0),
+		revisions:       make([]*VotersRevision, 0),
+		voters:          make(map[uint]*Voter),
+		collections:     make([]*VotingCollection, 0),
+		medianVotings:   make(map[uint]*MedianVoting),
+		schulzeVotings:  make(map[uint]*SchulzeVoting),
+		votingRevisions: make(map[uint]uint),
+		medianVotes:     make(map[uint][]*memoryMedianVote),
+		schulzeVotes:    make(map[uint]map[uint][]int),
+		userVoters:      make(map[userRevision]uint),
+		admins:          make(map[uint64]bool),
+	}
+}
+
+// nextID returns a new id, the mutex must be locked for writing.
+func (storage *MemoryStorage) nextID() uint {
+	storage.lastID++
+	return storage.lastID
+}
+
+func (storage *MemoryStorage) getRevision(id uint) *VotersRevision {
+	for _, revision := range storage.revisions {
+		if revision.ID == id {
+			return revision
+		}
+	}
+	return nil
+}
+
+func (storage *MemoryStorage) InsertCategory(name string) (uint, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	for _, category := range storage.categories {
+		if category.Name == name {
+			return InvalidID, fmt.Errorf("There is already a category with name \"%s\"", name)
+		}
+	}
+	id := storage.nextID()
+	storage.categories = append(storage.categories, &Category{Name: name, Created: Now(), ID: id})
+	return id, nil
+}
+
+func (storage *MemoryStorage) ListCategories() ([]*Category, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	res := make([]*Category, len(storage.categories))
+	for i, category := range storage.categories {
+		c := *category
+		res[i] = &c
+	}
+	return res, nil
+}
+
+func (storage *MemoryStorage) InsertVotersRevision(categoryID uint) (uint, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	found := false
+	for _, category := range storage.categories {
+		if category.ID == categoryID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return InvalidID, fmt.Errorf("There is no category with id %d", categoryID)
+	}
+	id := storage.nextID()
+	storage.revisions = append(storage.revisions, &VotersRevision{CategoryID: categoryID, Created: Now(), ID: id})
+	return id, nil
+}
+
+func (storage *MemoryStorage) ListVotersRevision(categoryID uint) ([]*VotersRevision, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	res := make([]*VotersRevision, 0)
+	for _, revision := range storage.revisions {
+		if categoryID == InvalidID || revision.CategoryID == categoryID {
+			r := *revision
+			res = append(res, &r)
+		}
+	}
+	return res, nil
+}
+
+func (storage *MemoryStorage) InsertVoters(revisionID uint, voters []*Voter) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	revision := storage.getRevision(revisionID)
+	if revision == nil {
+		return fmt.Errorf("There is no revision with id %d", revisionID)
+	}
+	return storage.insertVoters(revision, voters, storage.previousRevision(revision))
+}
+
+// previousRevision returns the revision created before revision in the same
+// category, nil if there is none.
+func (storage *MemoryStorage) previousRevision(revision *VotersRevision) *VotersRevision {
+	var previous *VotersRevision
+	for _, r := range storage.revisions {
+		if r.CategoryID == revision.CategoryID && r.ID < revision.ID {
+			previous = r
+		}
+	}
+	return previous
+}
+
+// insertVoters inserts the voters into the revision and carries forward the
+// user links from source (if not nil), the mutex must be locked for
+// writing.
+func (storage *MemoryStorage) insertVoters(revision *VotersRevision, voters []*Voter, source *VotersRevision) error {
+	names := make(map[string]bool)
+	for _, voter := range storage.voters {
+		if voter.RevisionID == revision.ID {
+			names[voter.Name] = true
+		}
+	}
+	for _, voter := range voters {
+		if names[voter.Name] {
+			return fmt.Errorf("Voter \"%s\" exists already in revision %d", voter.Name, revision.ID)
+		}
+		names[voter.Name] = true
+	}
+	newVoters := make(map[string]uint, len(voters))
+	for _, voter := range voters {
+		id := storage.nextID()
+		storage.voters[id] = &Voter{Name: voter.Name, Weight: voter.Weight, ID: id, RevisionID: revision.ID}
+		newVoters[voter.Name] = id
+	}
+	if source != nil {
+		storage.carryForwardUserVoters(source, revision, newVoters)
+	}
+	return nil
+}
+
+func (storage *MemoryStorage) CloneVotersRevision(revisionID uint, diff *VotersDiff) (uint, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	revision := storage.getRevision(revisionID)
+	if revision == nil {
+		return InvalidID, sql.ErrNoRows
+	}
+	voters := make([]*Voter, 0)
+	for _, voter := range storage.voters {
+		if voter.RevisionID == revisionID {
+			voters = append(voters, voter)
+		}
+	}
+	sort.Slice(voters, func(i, j int) bool { return voters[i].Name < voters[j].Name })
+	newVoters, err := diff.Apply(voters)
+	if err != nil {
+		return InvalidID, err
+	}
+	newRevision := &VotersRevision{CategoryID: revision.CategoryID, Created: Now(), ID: storage.nextID()}
+	storage.revisions = append(storage.revisions, newRevision)
+	// the names are unique, so inserting can't fail
+	storage.insertVoters(newRevision, newVoters, revision)
+	return newRevision.ID, nil
+}
+
+// carryForwardUserVoters links users to the voters in newVoters (mapping
+// voter names to ids) if they were linked to a voter with the same name in
+// source, see carryForwardUserVotersTx.
+func (storage *MemoryStorage) carryForwardUserVoters(source, revision *VotersRevision, newVoters map[string]uint) {
+	for key, voterID := range storage.userVoters {
+		if key.revisionID != source.ID {
+			continue
+		}
+		newID, has := newVoters[storage.voters[voterID].Name]
+		if !has {
+			continue
+		}
+		newKey := userRevision{userID: key.userID, revisionID: revision.ID}
+		if _, linked := storage.userVoters[newKey]; !linked {
+			storage.userVoters[newKey] = newID
+		}
+	}
+}
+
+func (storage *MemoryStorage) ListVoters(revisionID uint) ([]*Voter, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	res := make([]*Voter, 0)
+	for _, voter := range storage.voters {
+		if revisionID == InvalidID || voter.RevisionID == revisionID {
+			v := *voter
+			res = append(res, &v)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func (storage *MemoryStorage) GetVoterByName(revisionID uint, name string) (*Voter, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	for _, voter := range storage.voters {
+		if voter.RevisionID == revisionID && voter.Name == name {
+			v := *voter
+			return &v, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// checkCollectionNames checks the unique constraints for the names of the
+// groups and options in the collection, votings in a group may have the same
+// name.
+func checkCollectionNames(collection *VotingCollection) error {
+	groupNames := make(map[string]bool, len(collection.Groups))
+	for _, group := range collection.Groups {
+		if groupNames[group.Name] {
+			return fmt.Errorf("Group \"%s\" exists already in collection \"%s\"", group.Name, collection.Name)
+		}
+		groupNames[group.Name] = true
+		for _, voting := range group.SchulzeVotings {
+			options := make(map[string]bool, len(voting.Options))
+			for _, option := range voting.Options {
+				if options[option] {
+					return fmt.Errorf("Option \"%s\" exists already in voting \"%s\"", option, voting.Name)
+				}
+				options[option] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (storage *MemoryStorage) InsertVotingCollection(votersID uint, collection *VotingCollection) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if storage.getRevision(votersID) == nil {
+		return fmt.Errorf("There is no revision with id %d", votersID)
+	}
+	for _, c := range storage.collections {
+		if c.Name == collection.Name {
+			return fmt.Errorf("There is already a collection with name \"%s\"", collection.Name)
+		}
+	}
+	if err := checkCollectionNames(collection); err != nil {
+		return err
+	}
+	collection.ID, collection.VotersID = storage.nextID(), votersID
+	for _, group := range collection.Groups {
+		group.ID, group.CollectionID = storage.nextID(), collection.ID
+		group.NormalizePositions()
+		for _, voting := range group.MedianVotings {
+			voting.ID, voting.GroupID = storage.nextID(), group.ID
+		}
+		for _, voting := range group.SchulzeVotings {
+			voting.ID, voting.GroupID = storage.nextID(), group.ID
+			voting.OptionIDs = make([]uint, len(voting.Options))
+			for i := range voting.Options {
+				voting.OptionIDs[i] = storage.nextID()
+			}
+		}
+	}
+	stored := copyCollection(collection, true)
+	storage.collections = append(storage.collections, stored)
+	for _, group := range stored.Groups {
+		for _, voting := range group.MedianVotings {
+			storage.medianVotings[voting.ID] = voting
+			storage.votingRevisions[voting.ID] = votersID
+		}
+		for _, voting := range group.SchulzeVotings {
+			storage.schulzeVotings[voting.ID] = voting
+			storage.votingRevisions[voting.ID] = votersID
+		}
+	}
+	return nil
+}
+
+func copyMedianVoting(voting *MedianVoting) *MedianVoting {
+	res := *voting
+	return &res
+}
+
+func copySchulzeVoting(voting *SchulzeVoting) *SchulzeVoting {
+	res := *voting
+	res.Options = append(make([]string, 0, len(voting.Options)), voting.Options...)
+	res.OptionIDs = append(make([]uint, 0, len(voting.OptionIDs)), voting.OptionIDs...)
+	return &res
+}
+
+// copyCollection returns a copy of the collection, the groups are copied
+// only if withGroups is true.
+func copyCollection(collection *VotingCollection, withGroups bool) *VotingCollection {
+	res := *collection
+	res.Groups = make([]*VotingGroup, 0)
+	if !withGroups {
+		return &res
+	}
+	for _, group := range collection.Groups {
+		g := *group
+		g.MedianVotings = make([]*MedianVoting, len(group.MedianVotings))
+		for i, voting := range group.MedianVotings {
+			g.MedianVotings[i] = copyMedianVoting(voting)
+		}
+		g.SchulzeVotings = make([]*SchulzeVoting, len(group.SchulzeVotings))
+		for i, voting := range group.SchulzeVotings {
+			g.SchulzeVotings[i] = copySchulzeVoting(voting)
+		}
+		res.Groups = append(res.Groups, &g)
+	}
+	return &res
+}
+
+// listCollections returns copies without groups of all collections for which
+// accept returns true ordered by date.
+func (storage *MemoryStorage) listCollections(accept func(collection *VotingCollection) bool) []*VotingCollection {
+	res := make([]*VotingCollection, 0)
+	for _, collection := range storage.collections {
+		if accept(collection) {
+			res = append(res, copyCollection(collection, false))
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Date.Before(res[j].Date) })
+	return res
+}
+
+func (storage *MemoryStorage) ListVotingCollections(votersID uint) ([]*VotingCollection, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	return storage.listCollections(func(collection *VotingCollection) bool {
+		return votersID == InvalidID || collection.VotersID == votersID
+	}), nil
+}
+
+func (storage *MemoryStorage) ListUserCollections(userID uint64) ([]*VotingCollection, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	return storage.listCollections(func(collection *VotingCollection) bool {
+		_, linked := storage.userVoters[userRevision{userID: userID, revisionID: collection.VotersID}]
+		return linked
+	}), nil
+}
+
+func (storage *MemoryStorage) GetVotingCollection(id uint) (*VotingCollection, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	for _, collection := range storage.collections {
+		if collection.ID == id {
+			return copyCollection(collection, true), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (storage *MemoryStorage) GetMedianVoting(id uint) (*MedianVoting, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	voting, has := storage.medianVotings[id]
+	if !has {
+		return nil, sql.ErrNoRows
+	}
+	return copyMedianVoting(voting), nil
+}
+
+func (storage *MemoryStorage) GetSchulzeVoting(id uint) (*SchulzeVoting, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	voting, has := storage.schulzeVotings[id]
+	if !has {
+		return nil, sql.ErrNoRows
+	}
+	return copySchulzeVoting(voting), nil
+}
+
+func (storage *MemoryStorage) GetMedianVotingRevision(votingID uint) (uint, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if _, has := storage.medianVotings[votingID]; !has {
+		return InvalidID, sql.ErrNoRows
+	}
+	return storage.votingRevisions[votingID], nil
+}
+
+func (storage *MemoryStorage) GetSchulzeVotingRevision(votingID uint) (uint, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if _, has := storage.schulzeVotings[votingID]; !has {
+		return InvalidID, sql.ErrNoRows
+	}
+	return storage.votingRevisions[votingID], nil
+}
+
+// findMedianVote returns the vote of the voter in the median voting or nil.
+func (storage *MemoryStorage) findMedianVote(votingID, voterID uint) *memoryMedianVote {
+	for _, vote := range storage.medianVotes[votingID] {
+		if vote.voterID == voterID {
+			return vote
+		}
+	}
+	return nil
+}
+
+func (storage *MemoryStorage) InsertMedianVote(votingID, voterID uint, value int, abstain bool) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if _, has := storage.medianVotings[votingID]; !has {
+		return fmt.Errorf("There is no median voting with id %d", votingID)
+	}
+	if _, has := storage.voters[voterID]; !has {
+		return fmt.Errorf("There is no voter with id %d", voterID)
+	}
+	if storage.findMedianVote(votingID, voterID) != nil {
+		return fmt.Errorf("Voter %d already voted in median voting %d", voterID, votingID)
+	}
+	storage.medianVotes[votingID] = append(storage.medianVotes[votingID],
+		&memoryMedianVote{voterID: voterID, value: medianStoredValue(value, abstain), abstain: abstain})
+	return nil
+}
+
+func (storage *MemoryStorage) UpdateMedianVote(votingID, voterID uint, value int, abstain bool) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	vote := storage.findMedianVote(votingID, voterID)
+	if vote == nil {
+		return sql.ErrNoRows
+	}
+	vote.value, vote.abstain = medianStoredValue(value, abstain), abstain
+	return nil
+}
+
+func (storage *MemoryStorage) GetMedianVote(votingID, voterID uint) (int, bool, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	vote := storage.findMedianVote(votingID, voterID)
+	if vote == nil {
+		return 0, false, sql.ErrNoRows
+	}
+	return vote.value, vote.abstain, nil
+}
+
+func (storage *MemoryStorage) GetMedianVotes(votingID uint) ([]*MedianVote, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	revisionID := storage.votingRevisions[votingID]
+	res := make([]*MedianVote, 0)
+	for _, vote := range storage.medianVotes[votingID] {
+		voter := storage.voters[vote.voterID]
+		if voter.RevisionID == revisionID {
+			res = append(res, &MedianVote{Weight: voter.Weight, Value: vote.value,
+				Voter: voter.Name, Abstain: vote.abstain})
+		}
+	}
+	return res, nil
+}
+
+func (storage *MemoryStorage) InsertSchulzeVote(votingID, voterID uint, ranking []int) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	return storage.insertSchulzeVote(votingID, voterID, ranking)
+}
+
+func (storage *MemoryStorage) UpdateSchulzeVote(votingID, voterID uint, ranking []int) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	old, voted := storage.schulzeVotes[votingID][voterID]
+	delete(storage.schulzeVotes[votingID], voterID)
+	err := storage.insertSchulzeVote(votingID, voterID, ranking)
+	if err != nil && voted {
+		// keep the old vote as a rollback in SQLStorage does
+		storage.schulzeVotes[votingID][voterID] = old
+	}
+	return err
+}
+
+// insertSchulzeVote stores the ranking, the mutex must be locked for
+// writing.
+func (storage *MemoryStorage) insertSchulzeVote(votingID, voterID uint, ranking []int) error {
+	voting, has := storage.schulzeVotings[votingID]
+	if !has {
+		return fmt.Errorf("There is no schulze voting with id %d", votingID)
+	}
+	if _, has := storage.voters[voterID]; !has {
+		return fmt.Errorf("There is no voter with id %d", voterID)
+	}
+	if len(voting.Options) != len(ranking) {
+		return fmt.Errorf("Expected ranking of length %d, got length %d", len(voting.Options), len(ranking))
+	}
+	votes, has := storage.schulzeVotes[votingID]
+	if !has {
+		votes = make(map[uint][]int)
+		storage.schulzeVotes[votingID] = votes
+	}
+	if _, voted := votes[voterID]; voted {
+		return fmt.Errorf("Voter %d already voted in schulze voting %d", voterID, votingID)
+	}
+	votes[voterID] = append(make([]int, 0, len(ranking)), ranking...)
+	return nil
+}
+
+func (storage *MemoryStorage) GetSchulzeVote(votingID, voterID uint) ([]int, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	ranking, has := storage.schulzeVotes[votingID][voterID]
+	if !has {
+		return nil, sql.ErrNoRows
+	}
+	return append(make([]int, 0, len(ranking)), ranking...), nil
+}
+
+func (storage *MemoryStorage) GetSchulzeVotes(votingID uint) ([]*SchulzeVote, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	revisionID := storage.votingRevisions[votingID]
+	votes := storage.schulzeVotes[votingID]
+	// order by voter id as SQLStorage does
+	voterIDs := make([]uint, 0, len(votes))
+	for voterID := range votes {
+		voterIDs = append(voterIDs, voterID)
+	}
+	sort.Slice(voterIDs, func(i, j int) bool { return voterIDs[i] < voterIDs[j] })
+	res := make([]*SchulzeVote, 0, len(voterIDs))
+	for _, voterID := range voterIDs {
+		voter := storage.voters[voterID]
+		if voter.RevisionID == revisionID {
+			ranking := append(make([]int, 0, len(votes[voterID])), votes[voterID]...)
+			vote := NewSchulzeVote(voter.Weight, ranking)
+			vote.Abstain = RankingAbstains(ranking)
+			res = append(res, vote)
+		}
+	}
+	return res, nil
+}
+
+func (storage *MemoryStorage) LinkUserToVoter(userID uint64, voterID uint) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	voter, has := storage.voters[voterID]
+	if !has {
+		return fmt.Errorf("There is no voter with id %d", voterID)
+	}
+	storage.userVoters[userRevision{userID: userID, revisionID: voter.RevisionID}] = voterID
+	return nil
+}
+
+func (storage *MemoryStorage) UnlinkUser(userID uint64, revisionID uint) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	delete(storage.userVoters, userRevision{userID: userID, revisionID: revisionID})
+	return nil
+}
+
+func (storage *MemoryStorage) GetUserVoter(userID uint64, revisionID uint) (*Voter, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	voterID, has := storage.userVoters[userRevision{userID: userID, revisionID: revisionID}]
+	if !has {
+		return nil, sql.ErrNoRows
+	}
+	v := *storage.voters[voterID]
+	return &v, nil
+}
+
+func (storage *MemoryStorage) ListVoterUsers(revisionID uint) (map[uint][]uint64, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	res := make(map[uint][]uint64)
+	for key, voterID := range storage.userVoters {
+		if key.revisionID == revisionID {
+			res[voterID] = append(res[voterID], key.userID)
+		}
+	}
+	for _, users := range res {
+		sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+	}
+	return res, nil
+}
+
+func (storage *MemoryStorage) IsAdmin(userID uint64) (bool, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	return storage.admins[userID], nil
+}
+
+func (storage *MemoryStorage) SetAdmin(userID uint64, admin bool) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if admin {
+		storage.admins[userID] = true
+	} else {
+		delete(storage.admins, userID)
+	}
+	return nil
+}
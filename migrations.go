@@ -0,0 +1,254 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration is a change of the database schema. Each migration has the
+// statements for all dialects, they're executed in the given order.
+type migration struct {
+	version     int
+	description string
+	mysql       []string
+	sqlite      []string
+	// rebuildsTables is true if the SQLite statements rebuild tables that
+	// are referenced by foreign keys, foreign keys are disabled while the
+	// migration is applied (see https://www.sqlite.org/lang_altertable.html).
+	rebuildsTables bool
+}
+
+// statements returns the statements for the given dialect.
+func (m *migration) statements(dialect SQLDialect) []string {
+	if dialect == SQLiteDialect {
+		return m.sqlite
+	}
+	return m.mysql
+}
+
+// migrations contains all migrations ordered by version, the version of the
+// i-th migration is i + 1.
+// Migrations must never be changed once they're released, add a new
+// migration instead.
+var migrations = []*migration{
+	{1, "Create initial tables", mysqlSchema, sqliteSchema, false},
+	// existing votings get position 0, VotingGroup.Votings orders them as
+	// before: median votings first
+	{2, "Add positions of votings in their group", votingPositions, votingPositions, false},
+	// an empty status quo is the last option as before
+	{3, "Add status quo option of schulze votings", statusQuoOptions, statusQuoOptions, false},
+	// existing votes are no abstentions
+	{4, "Add abstentions in median votings", medianVoteAbstentions, medianVoteAbstentions, false},
+	// the position identifies a voting in its group, SQLite can't drop
+	// constraints so the tables are rebuilt
+	{5, "Allow votings with the same name in a group", mysqlVotingNames, sqliteVotingNames, true},
+}
+
+var votingPositions = []string{
+	"ALTER TABLE median_votings ADD COLUMN position INT NOT NULL DEFAULT 0;",
+	"ALTER TABLE schulze_votings ADD COLUMN position INT NOT NULL DEFAULT 0;",
+}
+
+var statusQuoOptions = []string{
+	"ALTER TABLE schulze_votings ADD COLUMN status_quo VARCHAR(150) NOT NULL DEFAULT '';",
+}
+
+var medianVoteAbstentions = []string{
+	"ALTER TABLE median_votes ADD COLUMN abstain BOOLEAN NOT NULL DEFAULT 0;",
+}
+
+// the foreign keys on group_id use the unique index in MySQL, so a new index
+// is added before the unique index is dropped
+var mysqlVotingNames = []string{
+	"ALTER TABLE median_votings ADD INDEX voting_group (group_id), DROP INDEX name_unique;",
+	"ALTER TABLE schulze_votings ADD INDEX voting_group (group_id), DROP INDEX name_unique;",
+}
+
+var sqliteVotingNames = []string{
+	`
+	CREATE TABLE median_votings_new (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		group_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		max_value INT,
+		percent_required DOUBLE,
+		position INT NOT NULL DEFAULT 0,
+		FOREIGN KEY (group_id)
+			REFERENCES voting_groups (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	INSERT INTO median_votings_new (id, group_id, name, max_value, percent_required, position)
+	SELECT id, group_id, name, max_value, percent_required, position FROM median_votings;
+	`,
+	"DROP TABLE median_votings;",
+	"ALTER TABLE median_votings_new RENAME TO median_votings;",
+	`
+	CREATE TABLE schulze_votings_new (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		group_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		percent_required DOUBLE,
+		position INT NOT NULL DEFAULT 0,
+		status_quo VARCHAR(150) NOT NULL DEFAULT '',
+		FOREIGN KEY (group_id)
+			REFERENCES voting_groups (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	INSERT INTO schulze_votings_new (id, group_id, name, percent_required, position, status_quo)
+	SELECT id, group_id, name, percent_required, position, status_quo FROM schulze_votings;
+	`,
+	"DROP TABLE schulze_votings;",
+	"ALTER TABLE schulze_votings_new RENAME TO schulze_votings;",
+}
+
+// LatestSchemaVersion is the schema version after all migrations have been
+// applied.
+var LatestSchemaVersion = len(migrations)
+
+// SchemaVersion returns the version of the schema in the database, 0 for a
+// new database. The schema_version table is created if it doesn't exist.
+func (storage *SQLStorage) SchemaVersion() (int, error) {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INT NOT NULL,
+		applied DATETIME NOT NULL,
+		PRIMARY KEY (version)
+	);
+	`
+	if _, err := storage.DB.Exec(query); err != nil {
+		return 0, err
+	}
+	var version int
+	err := storage.DB.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version;").Scan(&version)
+	return version, err
+}
+
+// CheckSchema returns the version of the schema in the database and an
+// error if the version is newer than LatestSchemaVersion, i.e. the database
+// was migrated by a newer version of this program.
+func (storage *SQLStorage) CheckSchema() (int, error) {
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		return version, err
+	}
+	if version > LatestSchemaVersion {
+		return version, fmt.Errorf("Database schema version %d is newer than the latest version %d known by this program, please update", version, LatestSchemaVersion)
+	}
+	return version, nil
+}
+
+// Migrate applies all migrations that haven't been applied yet, each
+// migration is applied in its own transaction. It returns the applied
+// migrations' versions.
+// Note that MySQL commits statements that change the schema immediately,
+// so only SQLite can roll back a failed migration completely.
+func (storage *SQLStorage) Migrate() ([]int, error) {
+	version, err := storage.CheckSchema()
+	if err != nil {
+		return nil, err
+	}
+	applied := make([]int, 0)
+	for _, m := range migrations[version:] {
+		if err := storage.applyMigration(m); err != nil {
+			return applied, fmt.Errorf("Migration %d (%s) failed: %s", m.version, m.description, err.Error())
+		}
+		storage.Logger.WithField("version", m.version).Info("Applied migration: ", m.description)
+		applied = append(applied, m.version)
+	}
+	return applied, nil
+}
+
+func (storage *SQLStorage) applyMigration(m *migration) error {
+	ctx := context.Background()
+	// PRAGMA foreign_keys only affects the connection and has no effect
+	// inside a transaction, so the migration uses its own connection
+	conn, err := storage.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	disableForeignKeys := m.rebuildsTables && storage.Dialect == SQLiteDialect
+	if disableForeignKeys {
+		if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF;"); err != nil {
+			return err
+		}
+		defer func() {
+			if _, enableErr := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON;"); enableErr != nil {
+				storage.Logger.WithError(enableErr).Error("Can't enable foreign keys after migration")
+			}
+		}()
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, query := range m.statements(storage.Dialect) {
+		if _, err = tx.Exec(query); err != nil {
+			break
+		}
+	}
+	if err == nil && disableForeignKeys {
+		err = checkForeignKeys(tx)
+	}
+	if err == nil {
+		_, err = tx.Exec("INSERT INTO schema_version (version, applied) VALUES (?, ?);", m.version, Now())
+	}
+	if err == nil {
+		return tx.Commit()
+	} else {
+		rollBackErr := tx.Rollback()
+		if rollBackErr != nil {
+			storage.Logger.WithError(rollBackErr).Error("Error while using Rollback in applyMigration")
+		}
+		return err
+	}
+}
+
+// checkForeignKeys returns an error if a foreign key constraint is violated
+// in a SQLite database, this must be checked after foreign keys were
+// disabled.
+func checkForeignKeys(tx *sql.Tx) error {
+	rows, err := tx.Query("PRAGMA foreign_key_check;")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var table string
+		var rowID sql.NullInt64
+		var parent string
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return err
+		}
+		return fmt.Errorf("Foreign key constraint violated in table %s (references %s)", table, parent)
+	}
+	return rows.Err()
+}
@@ -24,10 +24,223 @@ package sturavoting
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"sort"
 	"sync"
 )
 
+//// Majority ////
+
+// Majority is the fraction of the votes that must be exceeded to get a
+// majority. A valid majority is at least 0 and less than 1, a majority of 1
+// could never be exceeded.
+// The weight the majority is computed from is described by a
+// MajorityBasis, an absolute majority is SimpleMajority of the weight of all
+// eligible voters (see AbsoluteMajorityOptions).
+type Majority float64
+
+const (
+	// SimpleMajority requires more than half of the votes.
+	SimpleMajority Majority = 0.5
+	// TwoThirdsMajority requires more than two thirds of the votes, for
+	// example for changes of the statutes.
+	TwoThirdsMajority Majority = 2.0 / 3.0
+)
+
+// NewMajority returns the majority for the given fraction, it returns an
+// error if the fraction is not between 0 and 1.
+func NewMajority(fraction float64) (Majority, error) {
+	majority := Majority(fraction)
+	if err := majority.Validate(); err != nil {
+		return -1.0, err
+	}
+	return majority, nil
+}
+
+// NewFractionMajority returns the majority numerator / denominator, for
+// example NewFractionMajority(3, 4) for a three-quarters majority.
+func NewFractionMajority(numerator, denominator int) (Majority, error) {
+	if denominator <= 0 {
+		return -1.0, fmt.Errorf("Invalid majority %d/%d: Denominator must be positive", numerator, denominator)
+	}
+	return NewMajority(float64(numerator) / float64(denominator))
+}
+
+// Validate returns an error if the majority is not at least 0 and less
+// than 1, this includes placeholders like -1.0 the parser stores for
+// votings without a majority.
+func (majority Majority) Validate() error {
+	// also rejects NaN
+	if !(majority >= 0.0 && majority < 1.0) {
+		return fmt.Errorf("Invalid majority %v: Must be at least 0 and less than 1", float64(majority))
+	}
+	return nil
+}
+
+// VotesRequired returns the weight that must be exceeded for a majority if
+// weightSum is the weight of all votes.
+// It is computed with integer arithmetic from the fraction the majority
+// represents (see Fraction), so for example 29% of 100 is exactly 29.
+func (majority Majority) VotesRequired(weightSum int) int {
+	numerator, denominator := Fraction(float64(majority))
+	return int(int64(weightSum) * numerator / denominator)
+}
+
+// maxFractionDenominator is the greatest denominator Fraction returns.
+const maxFractionDenominator = 1000000
+
+// Fraction returns the fraction numerator / denominator closest to x with
+// a denominator of at most 1000000, x must be between 0 and 1.
+// Majorities are stored as floats, Fraction recovers the fraction they were
+// parsed from: 0.29 is 29/100 and 2.0 / 3.0 is 2/3.
+func Fraction(x float64) (int64, int64) {
+	// the convergents of the continued fraction of x, h / k is the last
+	// convergent and prevH / prevK the one before it
+	prevH, h := int64(0), int64(1)
+	prevK, k := int64(1), int64(0)
+	rest := x
+	for {
+		a := math.Floor(rest)
+		nextH, nextK := int64(a)*h+prevH, int64(a)*k+prevK
+		if nextK > maxFractionDenominator {
+			return h, k
+		}
+		prevH, h, prevK, k = h, nextH, k, nextK
+		if math.Abs(x-float64(h)/float64(k)) < 1e-9 || rest == a {
+			return h, k
+		}
+		rest = 1.0 / (rest - a)
+	}
+}
+
+func (majority Majority) String() string {
+	switch majority {
+	case SimpleMajority:
+		return "1/2"
+	case TwoThirdsMajority:
+		return "2/3"
+	default:
+		return FormatPercentRequired(float64(majority))
+	}
+}
+
+// MajorityBasis is the weight a majority is computed from.
+type MajorityBasis int
+
+const (
+	// CastBasis computes the majority from the weight of the votes cast.
+	CastBasis MajorityBasis = iota
+	// PresentBasis computes the majority from the weight of the voters
+	// present, voters present that didn't vote count as votes against.
+	PresentBasis
+	// EligibleBasis computes the majority from the weight of all eligible
+	// voters, i.e. an absolute majority.
+	EligibleBasis
+)
+
+func (basis MajorityBasis) String() string {
+	switch basis {
+	case CastBasis:
+		return "cast"
+	case PresentBasis:
+		return "present"
+	case EligibleBasis:
+		return "eligible"
+	default:
+		return fmt.Sprintf("MajorityBasis(%d)", int(basis))
+	}
+}
+
+// ParseMajorityBasis parses "cast", "present" or "eligible".
+func ParseMajorityBasis(str string) (MajorityBasis, error) {
+	for _, basis := range []MajorityBasis{CastBasis, PresentBasis, EligibleBasis} {
+		if str == basis.String() {
+			return basis, nil
+		}
+	}
+	return CastBasis, fmt.Errorf("Unknown majority basis \"%s\", expected cast, present or eligible", str)
+}
+
+// MarshalText encodes the basis as its name, used by the JSON and YAML
+// encodings.
+func (basis MajorityBasis) MarshalText() ([]byte, error) {
+	return []byte(basis.String()), nil
+}
+
+// UnmarshalText decodes a basis encoded with MarshalText.
+func (basis *MajorityBasis) UnmarshalText(text []byte) error {
+	var err error
+	*basis, err = ParseMajorityBasis(string(text))
+	return err
+}
+
+// Reference describes the weight a majority is computed from and the
+// quorum of a voting.
+type Reference struct {
+	// Basis is the basis of the majority.
+	Basis MajorityBasis
+	// Weight is the weight of the voters present for PresentBasis and the
+	// weight of all eligible voters for EligibleBasis, it is ignored for
+	// CastBasis.
+	Weight int
+	// Quorum is the weight of votes that must at least be cast for the
+	// voting to be valid, 0 if there is no quorum.
+	Quorum int
+}
+
+// CastReference returns the reference used if no reference is given: The
+// majority is computed from the votes cast and there is no quorum.
+func CastReference() *Reference {
+	return &Reference{Basis: CastBasis}
+}
+
+// MajorityResult describes how the majority of a voting was computed, it
+// is part of the results of all procedures.
+type MajorityResult struct {
+	// VotesRequired is the number of votes required for a majority.
+	VotesRequired int `json:"votes_required" yaml:"votes_required"`
+	// Basis is the basis VotesRequired was computed from.
+	Basis MajorityBasis `json:"basis" yaml:"basis"`
+	// ReferenceWeight is the weight VotesRequired was computed from.
+	ReferenceWeight int `json:"reference_weight" yaml:"reference_weight"`
+	// CastWeight is the weight of all votes cast, including abstentions.
+	CastWeight int `json:"cast_weight" yaml:"cast_weight"`
+	// AbstainedWeight is the weight of all abstentions.
+	AbstainedWeight int `json:"abstained_weight" yaml:"abstained_weight"`
+	// Quorum is the weight that had to be cast, 0 if there is no quorum.
+	Quorum int `json:"quorum" yaml:"quorum"`
+	// Valid is false if the quorum was not reached, the result of the
+	// voting must not be used in this case.
+	Valid bool `json:"valid" yaml:"valid"`
+}
+
+// computeMajority validates the majority and computes the votes required
+// from the reference, castWeight is the weight of all votes cast and
+// abstainedWeight the weight of the abstentions among them. Abstentions
+// count for the quorum but not for the weight of the votes cast.
+// A nil reference is the same as CastReference.
+func computeMajority(majority Majority, reference *Reference, castWeight, abstainedWeight int) (MajorityResult, error) {
+	if err := majority.Validate(); err != nil {
+		return MajorityResult{}, err
+	}
+	if reference == nil {
+		reference = CastReference()
+	}
+	referenceWeight := castWeight - abstainedWeight
+	if reference.Basis != CastBasis {
+		if castWeight > reference.Weight {
+			return MajorityResult{}, fmt.Errorf("The weight of the votes cast (%d) is greater than the %s weight (%d)",
+				castWeight, reference.Basis, reference.Weight)
+		}
+		referenceWeight = reference.Weight
+	}
+	return MajorityResult{VotesRequired: majority.VotesRequired(referenceWeight),
+		Basis: reference.Basis, ReferenceWeight: referenceWeight,
+		CastWeight: castWeight, AbstainedWeight: abstainedWeight, Quorum: reference.Quorum,
+		Valid: castWeight >= reference.Quorum}, nil
+}
+
 //// Median ////
 
 // MedianVote is used as a vote in a median procedure.
@@ -39,6 +252,13 @@ type MedianVote struct {
 
 	// Value is the value the voter chose.
 	Value int
+
+	// Voter is the name of the voter, it is only used to report the voters
+	// and may be empty.
+	Voter string
+
+	// Abstain is true if the voter abstained, Value is ignored in this case.
+	Abstain bool
 }
 
 // NewMedianVote returns a new MedianVote.
@@ -46,6 +266,11 @@ func NewMedianVote(weight, value int) *MedianVote {
 	return &MedianVote{Weight: weight, Value: value}
 }
 
+// NewMedianAbstention returns a new MedianVote for a voter that abstained.
+func NewMedianAbstention(weight int) *MedianVote {
+	return &MedianVote{Weight: weight, Abstain: true}
+}
+
 // SortMedianVotes sorts the votes according to the voted value.
 // Votes with hightest values come first.
 func SortMedianVotes(votes []*MedianVote) {
@@ -57,42 +282,87 @@ func SortMedianVotes(votes []*MedianVote) {
 	sort.Slice(votes, valueSort)
 }
 
+// MedianStep is a row in the table EvaluateMedian walks through.
+type MedianStep struct {
+	// Value is a value at least one voter voted for.
+	Value int `json:"value" yaml:"value"`
+	// Weight is the weight of all votes for at least Value.
+	Weight int `json:"weight" yaml:"weight"`
+	// Majority is true if Weight is greater than the votes required.
+	Majority bool `json:"majority" yaml:"majority"`
+}
+
 // MedianResult is a result type for median votings.
 type MedianResult struct {
-	// Value is the value that has a majority.
-	Value int
-	// VotesRequired is the number of votes required for a majority.
-	VotesRequired int
+	// Value is the value that has a majority, it is 0 if the quorum was not
+	// reached.
+	Value          int `json:"value" yaml:"value"`
+	MajorityResult `yaml:",inline"`
+	// Steps contains a step for each distinct value voted for, ordered by
+	// value from highest to lowest. Value is the value of the first step
+	// with a majority. Abstentions are not part of the steps.
+	Steps []*MedianStep `json:"steps" yaml:"steps"`
 }
 
 // EvaluateMedian evalues all votes given in votes and returns the
 // greatest value that has a majority.
-// percentRequired is a float and should be greater than 0 and lesser than
-// 1. It describes how many percents of all votes are required for a majority.
-// It returns 0 for value if no value was agreed upon.
-func EvaluateMedian(votes []*MedianVote, percentRequired float64) *MedianResult {
+// majority describes how many percents of the reference weight are
+// required for a majority, it returns an error if the majority is not valid.
+// reference is the weight the majority is computed from, nil computes it
+// from the votes cast. Abstentions count for the quorum, they're only part
+// of the reference weight if it isn't computed from the votes cast (see
+// computeMajority).
+// It returns 0 for value if no value was agreed upon or the quorum was not
+// reached.
+func EvaluateMedian(votes []*MedianVote, majority Majority, reference *Reference) (*MedianResult, error) {
 	SortMedianVotes(votes)
-	weightSum := 0
+	weightSum, abstainedWeight := 0, 0
 	for _, vote := range votes {
 		weightSum += vote.Weight
+		if vote.Abstain {
+			abstainedWeight += vote.Weight
+		}
+	}
+	majorityRes, err := computeMajority(majority, reference, weightSum, abstainedWeight)
+	if err != nil {
+		return nil, err
 	}
 	// votesRequired is the number that must be reached, i.e. the sum of
 	// weights for that value are *strictly* greather than this value.
-	votesRequired := int(float64(weightSum) * percentRequired)
+	votesRequired := majorityRes.VotesRequired
 	weightSoFar := 0
-	res := &MedianResult{VotesRequired: votesRequired}
+	res := &MedianResult{MajorityResult: majorityRes, Steps: make([]*MedianStep, 0)}
+	var step *MedianStep
 	for _, vote := range votes {
+		if vote.Abstain {
+			continue
+		}
 		weightSoFar += vote.Weight
-		if weightSoFar > votesRequired {
-			res.Value = vote.Value
+		if step == nil || step.Value != vote.Value {
+			step = &MedianStep{Value: vote.Value}
+			res.Steps = append(res.Steps, step)
+		}
+		step.Weight = weightSoFar
+		step.Majority = weightSoFar > votesRequired
+	}
+	for _, step := range res.Steps {
+		// no value is agreed upon if the quorum was not reached, even if
+		// the value has the majority of the votes cast
+		if step.Majority && majorityRes.Valid {
+			res.Value = step.Value
 			break
 		}
 	}
-	return res
+	return res, nil
 }
 
 //// Schulze ////
 
+// Unranked is the position of an option in a ranking that the voter
+// didn't rank, unranked options are tied and ranked below all ranked
+// options.
+const Unranked = -1
+
 // SchulzeVote is a vote used in the Schulze procedure.
 type SchulzeVote struct {
 	// Weight is the weight of the voter.
@@ -105,7 +375,12 @@ type SchulzeVote struct {
 	// For example: If there are three options and the first and third one should
 	// be equally preferred to option two the ranking would be
 	// [0, 1, 0].
+	// An option may be Unranked, so [0, Unranked, Unranked] prefers the first
+	// option to the other two.
 	Ranking []int
+	// Abstain is true if the voter abstained, Ranking is ignored in this
+	// case.
+	Abstain bool
 }
 
 // NewSchulzeVote returns a new SchulzeVote. See struct documentation for
@@ -114,6 +389,32 @@ func NewSchulzeVote(weight int, ranking []int) *SchulzeVote {
 	return &SchulzeVote{Weight: weight, Ranking: ranking}
 }
 
+// NewSchulzeAbstention returns a new SchulzeVote for a voter that
+// abstained.
+func NewSchulzeAbstention(weight int) *SchulzeVote {
+	return &SchulzeVote{Weight: weight, Abstain: true}
+}
+
+// RankingAbstains returns true if no option in ranking is ranked. The
+// storages store an abstention as such a ranking.
+func RankingAbstains(ranking []int) bool {
+	for _, position := range ranking {
+		if position != Unranked {
+			return false
+		}
+	}
+	return true
+}
+
+// rankingPosition returns the position of the option with index i in
+// ranking, Unranked options are ranked below all ranked options.
+func rankingPosition(ranking []int, i int) int {
+	if position := ranking[i]; position != Unranked {
+		return position
+	}
+	return math.MaxInt32
+}
+
 // IntMatrix is a quadratic matrix of integer values.
 type IntMatrix [][]int
 
@@ -144,41 +445,195 @@ func (m IntMatrix) Equals(other IntMatrix) bool {
 	return true
 }
 
+// TieBreaking selects how ties in the ranking of a schulze voting are
+// broken.
+type TieBreaking int
+
+const (
+	// ReportTies doesn't break ties, tied options are in the same group of
+	// the ranking.
+	ReportTies TieBreaking = iota
+	// RandomTies orders tied options by a random ranking of all options.
+	RandomTies
+	// BallotTies breaks ties with the ballots as proposed by Tideman: The
+	// ballots are picked in a random order (weighted by the voters' weights)
+	// and the first ballot that ranks two tied options differently decides.
+	// Options that are ranked equally by all ballots are ordered as in
+	// RandomTies.
+	BallotTies
+)
+
+func (mode TieBreaking) String() string {
+	switch mode {
+	case ReportTies:
+		return "report"
+	case RandomTies:
+		return "random"
+	case BallotTies:
+		return "ballots"
+	default:
+		return fmt.Sprintf("TieBreaking(%d)", int(mode))
+	}
+}
+
+// ParseTieBreaking parses "report", "random" or "ballots".
+func ParseTieBreaking(str string) (TieBreaking, error) {
+	for _, mode := range []TieBreaking{ReportTies, RandomTies, BallotTies} {
+		if str == mode.String() {
+			return mode, nil
+		}
+	}
+	return ReportTies, fmt.Errorf("Unknown tie breaking \"%s\", expected report, random or ballots", str)
+}
+
+// MarshalText encodes the mode as its name, used by the JSON and YAML
+// encodings.
+func (mode TieBreaking) MarshalText() ([]byte, error) {
+	return []byte(mode.String()), nil
+}
+
+// UnmarshalText decodes a mode encoded with MarshalText.
+func (mode *TieBreaking) UnmarshalText(text []byte) error {
+	var err error
+	*mode, err = ParseTieBreaking(string(text))
+	return err
+}
+
+// TieBreaker configures how ties are broken. The random choices are
+// generated from Seed, so the ranking is reproducible with the same seed.
+type TieBreaker struct {
+	Mode TieBreaking `json:"mode" yaml:"mode"`
+	Seed int64       `json:"seed" yaml:"seed"`
+}
+
 // SchulzeRes is the result returned by the schulze method.
 type SchulzeRes struct {
-	// VotesRequired is the number of votes required for a majority.
-	VotesRequired int
+	MajorityResult `yaml:",inline"`
 	// D is the matrix d as described in Wikipedia.
-	D IntMatrix
+	D IntMatrix `json:"d" yaml:"d"`
 	// P is the matrix p as described in Wikipedia.
-	P IntMatrix
+	P IntMatrix `json:"p" yaml:"p"`
 	// Ranked contains the result of the ranking algorithm.
 	// It contains a list of list of integers.
 	// The first list contains all options that are winners,
 	// the second list contains all options that are on the second place etc.
-	Ranked [][]int
-	// Percents is a list of length n - 1 containing the percentage of votes
-	// that voted the options 0...n-1 before no (no being the last option).
-	// So if there are three options and 50% voted option 1 before no and
-	// 75% voted option 2 before no this slice will be [0.5, 0.75].
-	Percents []float64
+	// Each list contains only one option unless ties are reported, see
+	// TieBreaker.
+	Ranked [][]int `json:"ranked" yaml:"ranked"`
+	// TieBreaker is the tie breaking used for Ranked.
+	TieBreaker TieBreaker `json:"tie_breaker" yaml:"tie_breaker"`
+	// StatusQuo is the index of the status quo option (usually "No").
+	StatusQuo int `json:"status_quo" yaml:"status_quo"`
+	// Percents is a list of length n containing the percentage of the
+	// reference weight (the weight VotesRequired is computed from) that
+	// voted each option before the status quo option, the entry for the
+	// status quo option itself is 0.
+	// So if there are three options, the last one is the status quo and 50%
+	// voted option 1 before it and 75% voted option 2 before it this slice
+	// will be [0.5, 0.75, 0].
+	Percents []float64 `json:"percents" yaml:"percents"`
+	// Verdicts contains the verdict for each option, an option is accepted if
+	// the weight of the votes that voted it before the status quo option
+	// (D[i][StatusQuo]) is greater than VotesRequired.
+	// All options are rejected if the quorum was not reached.
+	Verdicts []Verdict `json:"verdicts" yaml:"verdicts"`
+}
+
+// Verdict is the decision for an option of a schulze voting.
+type Verdict int
+
+const (
+	// Rejected means that the option didn't get the required majority
+	// against the status quo option.
+	Rejected Verdict = iota
+	// Accepted means that the option got the required majority against the
+	// status quo option.
+	Accepted
+	// StatusQuoVerdict is the verdict of the status quo option itself.
+	StatusQuoVerdict
+)
+
+func (verdict Verdict) String() string {
+	switch verdict {
+	case Rejected:
+		return "rejected"
+	case Accepted:
+		return "accepted"
+	case StatusQuoVerdict:
+		return "status quo"
+	default:
+		return fmt.Sprintf("Verdict(%d)", int(verdict))
+	}
+}
+
+// MarshalText encodes the verdict as its name, used by the JSON and YAML
+// encodings.
+func (verdict Verdict) MarshalText() ([]byte, error) {
+	return []byte(verdict.String()), nil
+}
+
+// UnmarshalText decodes a verdict encoded with MarshalText.
+func (verdict *Verdict) UnmarshalText(text []byte) error {
+	for _, v := range []Verdict{Rejected, Accepted, StatusQuoVerdict} {
+		if string(text) == v.String() {
+			*verdict = v
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown verdict \"%s\"", string(text))
+}
+
+// computeVerdicts returns the verdicts as defined in SchulzeRes.
+func computeVerdicts(d IntMatrix, n, statusQuo int, majority *MajorityResult) []Verdict {
+	res := make([]Verdict, n)
+	for i := range res {
+		switch {
+		case i == statusQuo:
+			res[i] = StatusQuoVerdict
+		case majority.Valid && d[i][statusQuo] > majority.VotesRequired:
+			res[i] = Accepted
+		default:
+			res[i] = Rejected
+		}
+	}
+	return res
 }
 
 // EvaluateSchulze evaluates the Schulze method.
 // votes contains all votes to be evaluated, n is the number of options in the
-// voting (so all votes must have a Ranking slice of length n) and
-// percentRequired is a float and should be greater than 0 and lesser than
-// 1. It describes how many percents of all votes are required for a majority.
-func EvaluateSchulze(votes []*SchulzeVote, n int, percentRequired float64) (*SchulzeRes, error) {
+// voting (so all votes that don't abstain must have a Ranking slice of
+// length n), statusQuo is
+// the index of the status quo option the other options are compared to and
+// majority describes how many percents of the reference weight are
+// required for a majority, it returns an error if the majority is not valid.
+// reference is the weight the majority is computed from, nil computes it
+// from the votes cast. Abstentions count for the quorum only, see
+// computeMajority.
+// tieBreaker configures how ties in the ranking are broken, nil reports
+// ties.
+func EvaluateSchulze(votes []*SchulzeVote, n, statusQuo int, majority Majority, reference *Reference, tieBreaker *TieBreaker) (*SchulzeRes, error) {
+	if n > 0 && (statusQuo < 0 || statusQuo >= n) {
+		return nil, fmt.Errorf("Invalid status quo option %d, must be between 0 and %d", statusQuo, n-1)
+	}
+	if tieBreaker != nil && (tieBreaker.Mode < ReportTies || tieBreaker.Mode > BallotTies) {
+		return nil, fmt.Errorf("Invalid tie breaking %s", tieBreaker.Mode)
+	}
 	// first compute votes required, check length of each result while doing this
-	weightSum := 0
+	weightSum, abstainedWeight := 0, 0
 	for _, vote := range votes {
 		weightSum += vote.Weight
+		if vote.Abstain {
+			abstainedWeight += vote.Weight
+			continue
+		}
 		if len(vote.Ranking) != n {
 			return nil, fmt.Errorf("Expected ranking of length %d, got length %d", n, len(vote.Ranking))
 		}
 	}
-	votesRequred := int(float64(weightSum) * percentRequired)
+	majorityRes, err := computeMajority(majority, reference, weightSum, abstainedWeight)
+	if err != nil {
+		return nil, err
+	}
 
 	d := computeD(votes, n)
 	// compute p and percents
@@ -192,12 +647,16 @@ func EvaluateSchulze(votes []*SchulzeVote, n int, percentRequired float64) (*Sch
 	}()
 	go func() {
 		defer wg.Done()
-		percents = computePercentage(d, n, weightSum)
+		percents = computePercentage(d, n, statusQuo, majorityRes.ReferenceWeight)
 	}()
 	wg.Wait()
-	ranked := rankP(p, n)
-	res := &SchulzeRes{VotesRequired: votesRequred, D: d, P: p,
-		Ranked: ranked, Percents: percents}
+	ranked := breakTies(rankP(p, n), votes, n, tieBreaker)
+	res := &SchulzeRes{MajorityResult: majorityRes, D: d, P: p,
+		Ranked: ranked, StatusQuo: statusQuo, Percents: percents,
+		Verdicts: computeVerdicts(d, n, statusQuo, &majorityRes)}
+	if tieBreaker != nil {
+		res.TieBreaker = *tieBreaker
+	}
 	return res, nil
 }
 
@@ -206,14 +665,18 @@ func EvaluateSchulze(votes []*SchulzeVote, n int, percentRequired float64) (*Sch
 func computeD(votes []*SchulzeVote, n int) IntMatrix {
 	res := NewIntMatrix(n)
 	for _, vote := range votes {
+		if vote.Abstain {
+			continue
+		}
 		w := vote.Weight
 		ranking := vote.Ranking
 		for i := 0; i < n; i++ {
 			for j := i + 1; j < n; j++ {
+				pi, pj := rankingPosition(ranking, i), rankingPosition(ranking, j)
 				switch {
-				case ranking[i] < ranking[j]:
+				case pi < pj:
 					res[i][j] += w
-				case ranking[j] < ranking[i]:
+				case pj < pi:
 					res[j][i] += w
 				}
 			}
@@ -222,18 +685,18 @@ func computeD(votes []*SchulzeVote, n int) IntMatrix {
 	return res
 }
 
-// computePercentage returns the percents slice as defined in SchulzeResult.
-func computePercentage(d IntMatrix, n, weightSum int) []float64 {
-	if n == 0 {
-		return nil
-	}
-	res := make([]float64, n-1)
+// computePercentage returns the percents slice as defined in SchulzeRes,
+// weightSum is the reference weight.
+func computePercentage(d IntMatrix, n, statusQuo, weightSum int) []float64 {
+	res := make([]float64, n)
 	if weightSum == 0 {
 		return res
 	}
 	weightSumF := float64(weightSum)
-	for i, row := range d[:n-1] {
-		res[i] = float64(row[n-1]) / weightSumF
+	for i, row := range d {
+		if i != statusQuo {
+			res[i] = float64(row[statusQuo]) / weightSumF
+		}
 	}
 	return res
 }
@@ -277,43 +740,103 @@ func computeP(d IntMatrix, n int) IntMatrix {
 	return res
 }
 
-// rankP ranks the matrix p, inspired by
-// https://github.com/mgp/schulze-method/blob/master/schulze.py
+// rankP computes the Schulze ranking from the matrix p: An option a beats
+// an option b iff p[a][b] > p[b][a], this relation is transitive.
+// The first group contains all options that are not beaten by any other
+// option, the second group all options that are only beaten by options in
+// the first group etc. Each group is sorted by option index.
 func rankP(p IntMatrix, n int) [][]int {
-	// wait for all i
-	var wg sync.WaitGroup
-	wg.Add(n)
-	candidateWins := make(map[int][]int)
-	// mutex used to sync writes to candidateWins
-	var mutex sync.Mutex
-	for i := 0; i < n; i++ {
-		go func(i int) {
-			defer wg.Done()
-			numWins := 0
-			for j := 0; j < n; j++ {
-				if i == j {
-					continue
-				}
-				if p[i][j] > p[j][i] {
-					numWins++
+	res := make([][]int, 0)
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	for len(remaining) > 0 {
+		winners := make([]int, 0)
+		rest := make([]int, 0, len(remaining))
+		for _, a := range remaining {
+			beaten := false
+			for _, b := range remaining {
+				if p[b][a] > p[a][b] {
+					beaten = true
+					break
 				}
 			}
-			// get list from the dict and append to it
-			mutex.Lock()
-			candidateWins[numWins] = append(candidateWins[numWins], i)
-			mutex.Unlock()
-		}(i)
+			if beaten {
+				rest = append(rest, a)
+			} else {
+				winners = append(winners, a)
+			}
+		}
+		res = append(res, winners)
+		remaining = rest
 	}
-	wg.Wait()
-	// get the keys from the dictionary and sort them (in reverse)
-	keys := make([]int, 0, len(candidateWins))
-	for key, _ := range candidateWins {
-		keys = append(keys, key)
-	}
-	sort.Sort(sort.Reverse(sort.IntSlice(keys)))
-	res := make([][]int, len(keys))
-	for i, key := range keys {
-		res[i] = candidateWins[key]
+	return res
+}
+
+// breakTies splits each group in ranked into single options as configured
+// in tieBreaker, a nil tieBreaker reports ties.
+func breakTies(ranked [][]int, votes []*SchulzeVote, n int, tieBreaker *TieBreaker) [][]int {
+	if tieBreaker == nil || tieBreaker.Mode == ReportTies {
+		return ranked
+	}
+	random := rand.New(rand.NewSource(tieBreaker.Seed))
+	// the position of each option in a random ranking
+	optionOrder := random.Perm(n)
+	var ballotOrder []int
+	if tieBreaker.Mode == BallotTies {
+		ballotOrder = weightedBallotOrder(votes, random)
+	}
+	less := func(a, b int) bool {
+		for _, k := range ballotOrder {
+			if votes[k].Abstain {
+				continue
+			}
+			ranking := votes[k].Ranking
+			if pa, pb := rankingPosition(ranking, a), rankingPosition(ranking, b); pa != pb {
+				return pa < pb
+			}
+		}
+		return optionOrder[a] < optionOrder[b]
+	}
+	res := make([][]int, 0, n)
+	for _, group := range ranked {
+		sorted := append(make([]int, 0, len(group)), group...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return less(sorted[i], sorted[j])
+		})
+		for _, option := range sorted {
+			res = append(res, []int{option})
+		}
+	}
+	return res
+}
+
+// weightedBallotOrder returns the indices of the votes in a random order,
+// the chance of a vote to be picked next is proportional to its weight.
+// Votes without a positive weight are never picked.
+func weightedBallotOrder(votes []*SchulzeVote, random *rand.Rand) []int {
+	remaining := make([]int, 0, len(votes))
+	for i, vote := range votes {
+		if vote.Weight > 0 {
+			remaining = append(remaining, i)
+		}
+	}
+	res := make([]int, 0, len(remaining))
+	cumulative := make([]int, len(remaining))
+	for len(remaining) > 0 {
+		// cumulative[j] is the sum of the weights of the first j + 1 votes
+		// not picked yet, the vote picked is the first one with a
+		// cumulative weight greater than the random number
+		total := 0
+		for j, i := range remaining {
+			total += votes[i].Weight
+			cumulative[j] = total
+		}
+		r := random.Intn(total)
+		j := sort.Search(len(remaining), func(j int) bool { return cumulative[j] > r })
+		res = append(res, remaining[j])
+		remaining = append(remaining[:j], remaining[j+1:]...)
 	}
 	return res
 }
@@ -23,6 +23,8 @@
 package sturavoting
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -32,7 +34,10 @@ func TestMedianOne(t *testing.T) {
 	v3 := NewMedianVote(2, 700)
 	v4 := NewMedianVote(2, 500)
 
-	res := EvaluateMedian([]*MedianVote{v1, v2, v3, v4}, 0.5)
+	res, err := EvaluateMedian([]*MedianVote{v1, v2, v3, v4}, SimpleMajority, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if res.VotesRequired != 5 {
 		t.Errorf("Expected 5 required votes in median, got %d", res.VotesRequired)
 	}
@@ -40,6 +45,16 @@ func TestMedianOne(t *testing.T) {
 	if res.Value != 500 {
 		t.Errorf("Expected value of 500 in median, got %d", res.Value)
 	}
+
+	expected := []MedianStep{{1000, 3, false}, {700, 5, false}, {500, 7, true}, {200, 11, true}}
+	if len(res.Steps) != len(expected) {
+		t.Fatalf("Expected %d steps in median, got %d", len(expected), len(res.Steps))
+	}
+	for i, step := range res.Steps {
+		if *step != expected[i] {
+			t.Errorf("Expected step %+v in median, got %+v", expected[i], *step)
+		}
+	}
 }
 
 func TestMedianTwo(t *testing.T) {
@@ -48,7 +63,10 @@ func TestMedianTwo(t *testing.T) {
 	v2 := NewMedianVote(2, 150)
 	v3 := NewMedianVote(3, 200)
 
-	res := EvaluateMedian([]*MedianVote{v1, v2, v3}, 0.5)
+	res, err := EvaluateMedian([]*MedianVote{v1, v2, v3}, SimpleMajority, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if res.VotesRequired != 3 {
 		t.Errorf("Expected 3 required votes in median, got %d", res.VotesRequired)
 	}
@@ -63,7 +81,7 @@ func TestSchulzeOne(t *testing.T) {
 	v1 := NewSchulzeVote(1, []int{0, 0, 0, 0, 0, 1})
 	v2 := NewSchulzeVote(2, []int{0, 0, 1, 3, 0, 2})
 	v3 := NewSchulzeVote(3, []int{1, 1, 0, 2, 2, 3})
-	res, err := EvaluateSchulze([]*SchulzeVote{v1, v2, v3}, 6, 0.5)
+	res, err := EvaluateSchulze([]*SchulzeVote{v1, v2, v3}, 6, 5, SimpleMajority, nil, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -114,7 +132,7 @@ func TestSchulzeTwo(t *testing.T) {
 	v7 := NewSchulzeVote(7, []int{4, 3, 1, 0, 2})
 	v8 := NewSchulzeVote(8, []int{2, 1, 4, 3, 0})
 
-	res, err := EvaluateSchulze([]*SchulzeVote{v1, v2, v3, v4, v5, v6, v7, v8}, 5, 0.5)
+	res, err := EvaluateSchulze([]*SchulzeVote{v1, v2, v3, v4, v5, v6, v7, v8}, 5, 4, SimpleMajority, nil, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -153,7 +171,7 @@ func TestSchuleThree(t *testing.T) {
 	v3 := NewSchulzeVote(2, []int{3, 1, 2, 0})
 	v4 := NewSchulzeVote(2, []int{3, 1, 0, 2})
 
-	res, err := EvaluateSchulze([]*SchulzeVote{v1, v2, v3, v4}, 4, 0.5)
+	res, err := EvaluateSchulze([]*SchulzeVote{v1, v2, v3, v4}, 4, 3, SimpleMajority, nil, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -177,3 +195,269 @@ func TestSchuleThree(t *testing.T) {
 		return
 	}
 }
+
+func TestMajority(t *testing.T) {
+	for _, fraction := range []float64{-1.0, -0.1, 1.0, 1.01, math.NaN(), math.Inf(1)} {
+		if _, err := NewMajority(fraction); err == nil {
+			t.Errorf("Expected error for majority %v", fraction)
+		}
+	}
+	if _, err := NewFractionMajority(2, 0); err == nil {
+		t.Error("Expected error for denominator 0")
+	}
+	if _, err := NewFractionMajority(1, 1); err == nil {
+		t.Error("Expected error for majority 1/1")
+	}
+	majority, err := NewFractionMajority(2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if majority != TwoThirdsMajority || majority.String() != "2/3" {
+		t.Errorf("Expected two-thirds majority, got %s", majority)
+	}
+	if required := TwoThirdsMajority.VotesRequired(9); required != 6 {
+		t.Errorf("Expected 6 votes required for 2/3 of 9, got %d", required)
+	}
+	// 0.29 * 100 is 28.999999999999996 as a float
+	if required := Majority(0.29).VotesRequired(100); required != 29 {
+		t.Errorf("Expected 29 votes required for 29%% of 100, got %d", required)
+	}
+	for x, expected := range map[float64][2]int64{0.29: {29, 100}, 2.0 / 3.0: {2, 3}, 0.5: {1, 2}, 0.0: {0, 1}, 1.0 / 7.0: {1, 7}} {
+		if numerator, denominator := Fraction(x); numerator != expected[0] || denominator != expected[1] {
+			t.Errorf("Expected fraction %d/%d for %v, got %d/%d", expected[0], expected[1], x, numerator, denominator)
+		}
+	}
+	// exactly 29% is not more than 29%
+	res, err := EvaluateMedian([]*MedianVote{NewMedianVote(29, 100), NewMedianVote(71, 0)}, 0.29, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != 0 {
+		t.Errorf("Expected value 0 with exactly 29%% for 100, got %d", res.Value)
+	}
+	votes := []*MedianVote{NewMedianVote(1, 100)}
+	if _, err := EvaluateMedian(votes, -1.0, nil); err == nil {
+		t.Error("Expected EvaluateMedian to reject majority -1")
+	}
+	if _, err := EvaluateSchulze([]*SchulzeVote{NewSchulzeVote(1, []int{0, 1})}, 2, 1, 1.5, nil, nil); err == nil {
+		t.Error("Expected EvaluateSchulze to reject majority 1.5")
+	}
+}
+
+func TestMedianReference(t *testing.T) {
+	votes := []*MedianVote{NewMedianVote(3, 200), NewMedianVote(2, 100)}
+	// 5 votes cast, more than 4 of 8 present required
+	res, err := EvaluateMedian(votes, SimpleMajority, &Reference{Basis: PresentBasis, Weight: 8, Quorum: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.VotesRequired != 4 || res.ReferenceWeight != 8 || res.CastWeight != 5 || res.Basis != PresentBasis {
+		t.Errorf("Expected more than 4 of 8 present required, got %+v", res.MajorityResult)
+	}
+	if res.Valid {
+		t.Error("Expected voting to be invalid, quorum is 6 and weight 5 was cast")
+	}
+	if res.Value != 0 {
+		t.Errorf("Expected no value for an invalid voting, got %d", res.Value)
+	}
+	if len(res.Steps) != 2 || !res.Steps[1].Majority {
+		t.Errorf("Expected steps to be reported for an invalid voting, got %v", res.Steps)
+	}
+	res, err = EvaluateMedian(votes, SimpleMajority, &Reference{Basis: PresentBasis, Weight: 8, Quorum: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.Value != 100 {
+		t.Errorf("Expected value 100 for a valid voting, got %d", res.Value)
+	}
+	if _, err = EvaluateMedian(votes, SimpleMajority, &Reference{Basis: EligibleBasis, Weight: 4}); err == nil {
+		t.Error("Expected error if more weight was cast than the reference weight")
+	}
+}
+
+func TestRankP(t *testing.T) {
+	// 0 beats 1 beats 2, 3 ties with all options
+	// counting wins would rank 3 together with 2
+	p := IntMatrix{[]int{0, 5, 5, 4},
+		[]int{3, 0, 5, 4},
+		[]int{3, 3, 0, 4},
+		[]int{4, 4, 4, 0}}
+	expected := [][]int{[]int{0, 3}, []int{1}, []int{2}}
+	if ranked := rankP(p, 4); !compareSlices(ranked, expected) {
+		t.Errorf("Expected ranking %v, got %v", expected, ranked)
+	}
+}
+
+func TestWeightedBallotOrder(t *testing.T) {
+	// the order must not depend on the size of the weights
+	votes := []*SchulzeVote{NewSchulzeVote(1<<30, []int{0, 1}), NewSchulzeVote(0, []int{1, 0}),
+		NewSchulzeVote(3, []int{1, 0})}
+	first := 0
+	random := rand.New(rand.NewSource(42))
+	for i := 0; i < 100; i++ {
+		order := weightedBallotOrder(votes, random)
+		if len(order) != 2 {
+			t.Fatalf("Expected two votes with a positive weight in the order, got %v", order)
+		}
+		if order[0] == 0 {
+			first++
+		}
+	}
+	if first < 99 {
+		t.Errorf("Expected the vote with the greatest weight to be picked first almost always, got %d of 100", first)
+	}
+	votes = []*SchulzeVote{NewSchulzeVote(3, []int{0, 1}), NewSchulzeVote(1, []int{1, 0})}
+	first = 0
+	for i := 0; i < 4000; i++ {
+		if weightedBallotOrder(votes, random)[0] == 0 {
+			first++
+		}
+	}
+	// the chance is 3/4
+	if first < 2800 || first > 3200 {
+		t.Errorf("Expected the vote with weight 3 to be picked first about 3000 of 4000 times, got %d", first)
+	}
+}
+
+func TestSchulzeTieBreaking(t *testing.T) {
+	// Example from Wikipedia, see TestSchuleThree
+	votes := []*SchulzeVote{NewSchulzeVote(3, []int{0, 1, 2, 3}),
+		NewSchulzeVote(2, []int{1, 2, 3, 0}),
+		NewSchulzeVote(2, []int{3, 1, 2, 0}),
+		NewSchulzeVote(2, []int{3, 1, 0, 2})}
+	res, err := EvaluateSchulze(votes, 4, 3, SimpleMajority, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int{[]int{1, 3}, []int{0, 2}}
+	if !compareSlices(res.Ranked, expected) {
+		t.Errorf("Expected ranking %v with ties, got %v", expected, res.Ranked)
+	}
+	for _, mode := range []TieBreaking{RandomTies, BallotTies} {
+		tieBreaker := &TieBreaker{Mode: mode, Seed: 42}
+		first, err := EvaluateSchulze(votes, 4, 3, SimpleMajority, nil, tieBreaker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := EvaluateSchulze(votes, 4, 3, SimpleMajority, nil, tieBreaker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !compareSlices(first.Ranked, second.Ranked) {
+			t.Errorf("Expected the same ranking for the same seed with %s, got %v and %v", mode, first.Ranked, second.Ranked)
+		}
+		if len(first.Ranked) != 4 {
+			t.Fatalf("Expected all ties to be broken with %s, got %v", mode, first.Ranked)
+		}
+		// ties are broken inside the groups only
+		top := map[int]bool{first.Ranked[0][0]: true, first.Ranked[1][0]: true}
+		if !top[1] || !top[3] {
+			t.Errorf("Expected options 1 and 3 to be ranked first with %s, got %v", mode, first.Ranked)
+		}
+		if first.TieBreaker != *tieBreaker {
+			t.Errorf("Expected tie breaker %v in result, got %v", *tieBreaker, first.TieBreaker)
+		}
+	}
+	if _, err := EvaluateSchulze(votes, 4, 3, SimpleMajority, nil, &TieBreaker{Mode: 5}); err == nil {
+		t.Error("Expected error for invalid tie breaking")
+	}
+}
+
+func TestSchulzeVerdicts(t *testing.T) {
+	// option 1 is the status quo, 5 of 9 voted option 0 before it, 3 voted
+	// option 2 before it
+	votes := []*SchulzeVote{NewSchulzeVote(5, []int{0, 1, 2}),
+		NewSchulzeVote(3, []int{2, 1, 0}),
+		NewSchulzeVote(1, []int{1, 0, 2})}
+	res, err := EvaluateSchulze(votes, 3, 1, SimpleMajority, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Verdict{Accepted, StatusQuoVerdict, Rejected}
+	for i, verdict := range expected {
+		if res.Verdicts[i] != verdict {
+			t.Errorf("Expected verdict %s for option %d, got %s", verdict, i, res.Verdicts[i])
+		}
+	}
+	if res.Percents[0] != 5.0/9.0 || res.Percents[1] != 0 || res.Percents[2] != 3.0/9.0 {
+		t.Errorf("Expected percents [5/9 0 3/9], got %v", res.Percents)
+	}
+	// 5 of 9 is not a two-thirds majority
+	res, err = EvaluateSchulze(votes, 3, 1, TwoThirdsMajority, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Verdicts[0] != Rejected {
+		t.Errorf("Expected option 0 to be rejected with a two-thirds majority, got %s", res.Verdicts[0])
+	}
+	// the quorum isn't reached
+	res, err = EvaluateSchulze(votes, 3, 1, SimpleMajority, &Reference{Basis: CastBasis, Quorum: 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Verdicts[0] != Rejected {
+		t.Errorf("Expected option 0 to be rejected if the quorum isn't reached, got %s", res.Verdicts[0])
+	}
+	// percents and the votes required are computed from the same weight:
+	// 5 of 12 present voted option 0 before the status quo, that is not
+	// more than 6
+	res, err = EvaluateSchulze(votes, 3, 1, SimpleMajority, &Reference{Basis: PresentBasis, Weight: 12}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Percents[0] != 5.0/12.0 || res.Verdicts[0] != Rejected {
+		t.Errorf("Expected option 0 to be rejected with 5/12, got %s with %v", res.Verdicts[0], res.Percents[0])
+	}
+	if _, err = EvaluateSchulze(votes, 3, 3, SimpleMajority, nil, nil); err == nil {
+		t.Error("Expected error for an invalid status quo option")
+	}
+}
+
+func TestSchulzeAbstentions(t *testing.T) {
+	// the second vote ranks only option 2, options 0 and 1 are tied last
+	votes := []*SchulzeVote{NewSchulzeVote(3, []int{0, 1, 2}),
+		NewSchulzeVote(2, []int{Unranked, Unranked, 0}),
+		NewSchulzeAbstention(4)}
+	expectedD := IntMatrix{{0, 3, 3}, {0, 0, 3}, {2, 2, 0}}
+	res, err := EvaluateSchulze(votes, 3, 2, SimpleMajority, &Reference{Basis: CastBasis, Quorum: 8}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.D.Equals(expectedD) {
+		t.Errorf("Expected d %v, got %v", expectedD, res.D)
+	}
+	// the abstention counts for the quorum but not for the majority
+	if res.CastWeight != 9 || res.AbstainedWeight != 4 || res.ReferenceWeight != 5 || res.VotesRequired != 2 || !res.Valid {
+		t.Errorf("Expected more than 2 of 5 votes required and a valid voting, got %+v", res.MajorityResult)
+	}
+	if res.Verdicts[0] != Accepted || res.Percents[0] != 3.0/5.0 {
+		t.Errorf("Expected option 0 to be accepted with 3/5, got %s with %v", res.Verdicts[0], res.Percents[0])
+	}
+	if _, err = EvaluateSchulze(votes, 3, 2, SimpleMajority, nil, &TieBreaker{Mode: BallotTies}); err != nil {
+		t.Errorf("Expected ballot tie breaking to ignore abstentions, got %v", err)
+	}
+}
+
+func TestMedianAbstentions(t *testing.T) {
+	votes := []*MedianVote{NewMedianVote(3, 200), NewMedianVote(2, 100), NewMedianAbstention(2)}
+	// the abstention doesn't count for the majority of the votes cast
+	res, err := EvaluateMedian(votes, SimpleMajority, &Reference{Basis: CastBasis, Quorum: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CastWeight != 7 || res.AbstainedWeight != 2 || res.VotesRequired != 2 || !res.Valid {
+		t.Errorf("Expected more than 2 of 5 votes required and a valid voting, got %+v", res.MajorityResult)
+	}
+	if res.Value != 200 || len(res.Steps) != 2 {
+		t.Errorf("Expected value 200 and two steps, got %d and %v", res.Value, res.Steps)
+	}
+	// but it counts as a vote against if the majority is computed from the
+	// voters present
+	res, err = EvaluateMedian(votes, SimpleMajority, &Reference{Basis: PresentBasis, Weight: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.VotesRequired != 3 || res.Value != 100 {
+		t.Errorf("Expected more than 3 votes required and value 100, got %d and %d", res.VotesRequired, res.Value)
+	}
+}
@@ -25,182 +25,408 @@ package sturavoting
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 const InvalidID = ^uint(0)
 
+// SQLDialect describes the database system used to store the votings.
+type SQLDialect int
+
+const (
+	MySQLDialect SQLDialect = iota
+	SQLiteDialect
+)
+
+func (dialect SQLDialect) String() string {
+	switch dialect {
+	case MySQLDialect:
+		return "mysql"
+	case SQLiteDialect:
+		return "sqlite"
+	default:
+		return fmt.Sprintf("SQLDialect(%d)", int(dialect))
+	}
+}
+
+// insertIgnore returns the start of an insert statement that silently skips
+// rows violating a unique constraint.
+func (dialect SQLDialect) insertIgnore() string {
+	if dialect == SQLiteDialect {
+		return "INSERT OR IGNORE"
+	}
+	return "INSERT IGNORE"
+}
+
 // DefaultTimeFromScanType is the default function to return database entries
 // to a time.Time.
 func TimeFromScanType(val interface{}) (time.Time, error) {
 	// first check if we already got a time.Time because parseTime in
-	// the MySQL driver is true
+	// the MySQL driver is true or the SQLite driver parsed a DATETIME column
 	if alreadyTime, ok := val.(time.Time); ok {
 		return alreadyTime, nil
 	}
-	if bytes, ok := val.([]byte); ok {
-		s := string(bytes)
-		// let's hope this is correct... however who came up with THIS parse
-		// function definition in Go?!
-		return time.Parse("2006-01-02 15:04:05", s)
-	} else {
+	var s string
+	switch v := val.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
 		// we have to return some time... why not now.
 		return time.Now().UTC(), errors.New("Invalid date in database, probably a bug if you end up here.")
 	}
+	// let's hope this is correct... however who came up with THIS parse
+	// function definition in Go?!
+	return time.Parse("2006-01-02 15:04:05", s)
 }
 
-func initDB(db *sql.DB) error {
-	queries := []string{
-		`
-		CREATE TABLE IF NOT EXISTS categories (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			name VARCHAR(150) NOT NULL,
-			created DATETIME NOT NULL,
-			PRIMARY KEY (id),
-			CONSTRAINT name_unique UNIQUE (name)
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS voters_revisions (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			category_id BIGINT UNSIGNED NOT NULL,
-			created DATETIME NOT NULL,
-			PRIMARY KEY (id),
-			FOREIGN KEY (category_id)
-				REFERENCES categories (id)
-				ON DELETE CASCADE
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS voters (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			revision_id BIGINT UNSIGNED NOT NULL,
-			name VARCHAR(150),
-			weight INT,
-			PRIMARY KEY (id),
-			CONSTRAINT name_unique UNIQUE (revision_id, name),
-			FOREIGN KEY (revision_id)
-				REFERENCES voters_revisions (id)
-				ON DELETE CASCADE
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS voting_collections (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			voters_id BIGINT UNSIGNED NOT NULL,
-			name VARCHAR(150),
-			voting_day DATETIME,
-			PRIMARY KEY (id),
-			CONSTRAINT name_unique UNIQUE (name),
-			FOREIGN KEY (voters_id)
-				REFERENCES voters_revisions (id)
-				ON DELETE CASCADE
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS voting_groups (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			collection_id BIGINT UNSIGNED NOT NULL,
-			name VARCHAR(150),
-			PRIMARY KEY (id),
-			CONSTRAINT name_unique UNIQUE (collection_id, name),
-			FOREIGN KEY (collection_id)
-				REFERENCES voting_collections (id)
-				ON DELETE CASCADE
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS median_votings (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			group_id BIGINT UNSIGNED NOT NULL,
-			name VARCHAR(150),
-			max_value INT,
-			percent_required DOUBLE,
-			PRIMARY KEY (id),
-			CONSTRAINT name_unique UNIQUE (group_id, name),
-			FOREIGN KEY (group_id)
-				REFERENCES voting_groups (id)
-				ON DELETE CASCADE
-		);
-		`,
-		// name should be unique in both tables
-		// but we cannot enforce this
-		`
-		CREATE TABLE IF NOT EXISTS schulze_votings (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			group_id BIGINT UNSIGNED NOT NULL,
-			name VARCHAR(150),
-			percent_required DOUBLE,
-			PRIMARY KEY (id),
-			CONSTRAINT name_unique UNIQUE (group_id, name),
-			FOREIGN KEY (group_id)
-				REFERENCES voting_groups (id)
-				ON DELETE CASCADE
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS schulze_options (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			voting_id BIGINT UNSIGNED NOT NULL,
-			option VARCHAR(150),
-			PRIMARY KEY (id),
-			CONSTRAINT option_unique UNIQUE (voting_id, option),
-			FOREIGN KEY (voting_id)
-				REFERENCES schulze_votings (id)
-				ON DELETE CASCADE
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS median_votes (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			voting_id BIGINT UNSIGNED NOT NULL,
-			voter_id BIGINT UNSIGNED NOT NULL,
-			value INT,
-			PRIMARY KEY (id),
-			CONSTRAINT vote_unique UNIQUE (voting_id, voter_id),
-			FOREIGN KEY (voting_id)
-				REFERENCES median_votings (id)
-				ON DELETE CASCADE,
-			FOREIGN KEY (voter_id)
-				REFERENCES voters (id)
-				ON DELETE CASCADE
-		);
-		`,
-		`
-		CREATE TABLE IF NOT EXISTS schulze_votes (
-			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
-			option_id BIGINT UNSIGNED NOT NULL,
-			voter_id BIGINT UNSIGNED NOT NULL,
-			sorting_position INT,
-			PRIMARY KEY (id),
-			CONSTRAINT option_vote_unique UNIQUE (option_id, voter_id),
-			FOREIGN KEY (option_id)
-				REFERENCES schulze_options (id)
-				ON DELETE CASCADE,
-			FOREIGN KEY (voter_id)
-				REFERENCES voters (id)
-				ON DELETE CASCADE
-		);
-		`,
-	}
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return err
-		}
-	}
-	return nil
+// mysqlSchema contains the statements to create all tables in MySQL, it is
+// the first migration in migrations.
+// Because the tables were created without a schema version before, the
+// statements must not fail if the tables already exist.
+var mysqlSchema = []string{
+	`
+	CREATE TABLE IF NOT EXISTS categories (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		name VARCHAR(150) NOT NULL,
+		created DATETIME NOT NULL,
+		PRIMARY KEY (id),
+		CONSTRAINT name_unique UNIQUE (name)
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voters_revisions (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		category_id BIGINT UNSIGNED NOT NULL,
+		created DATETIME NOT NULL,
+		PRIMARY KEY (id),
+		FOREIGN KEY (category_id)
+			REFERENCES categories (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voters (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		revision_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		weight INT,
+		PRIMARY KEY (id),
+		CONSTRAINT name_unique UNIQUE (revision_id, name),
+		FOREIGN KEY (revision_id)
+			REFERENCES voters_revisions (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voting_collections (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		voters_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		voting_day DATETIME,
+		PRIMARY KEY (id),
+		CONSTRAINT name_unique UNIQUE (name),
+		FOREIGN KEY (voters_id)
+			REFERENCES voters_revisions (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voting_groups (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		collection_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		PRIMARY KEY (id),
+		CONSTRAINT name_unique UNIQUE (collection_id, name),
+		FOREIGN KEY (collection_id)
+			REFERENCES voting_collections (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS median_votings (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		group_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		max_value INT,
+		percent_required DOUBLE,
+		PRIMARY KEY (id),
+		CONSTRAINT name_unique UNIQUE (group_id, name),
+		FOREIGN KEY (group_id)
+			REFERENCES voting_groups (id)
+			ON DELETE CASCADE
+	);
+	`,
+	// name should be unique in both tables
+	// but we cannot enforce this
+	`
+	CREATE TABLE IF NOT EXISTS schulze_votings (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		group_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		percent_required DOUBLE,
+		PRIMARY KEY (id),
+		CONSTRAINT name_unique UNIQUE (group_id, name),
+		FOREIGN KEY (group_id)
+			REFERENCES voting_groups (id)
+			ON DELETE CASCADE
+	);
+	`,
+	// option is a reserved word in MySQL, so it must be quoted
+	`
+	CREATE TABLE IF NOT EXISTS schulze_options (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		voting_id BIGINT UNSIGNED NOT NULL,
+		` + "`option`" + ` VARCHAR(150),
+		PRIMARY KEY (id),
+		CONSTRAINT option_unique UNIQUE (voting_id, ` + "`option`" + `),
+		FOREIGN KEY (voting_id)
+			REFERENCES schulze_votings (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS median_votes (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		voting_id BIGINT UNSIGNED NOT NULL,
+		voter_id BIGINT UNSIGNED NOT NULL,
+		value INT,
+		PRIMARY KEY (id),
+		CONSTRAINT vote_unique UNIQUE (voting_id, voter_id),
+		FOREIGN KEY (voting_id)
+			REFERENCES median_votings (id)
+			ON DELETE CASCADE,
+		FOREIGN KEY (voter_id)
+			REFERENCES voters (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS schulze_votes (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		option_id BIGINT UNSIGNED NOT NULL,
+		voter_id BIGINT UNSIGNED NOT NULL,
+		sorting_position INT,
+		PRIMARY KEY (id),
+		CONSTRAINT option_vote_unique UNIQUE (option_id, voter_id),
+		FOREIGN KEY (option_id)
+			REFERENCES schulze_options (id)
+			ON DELETE CASCADE,
+		FOREIGN KEY (voter_id)
+			REFERENCES voters (id)
+			ON DELETE CASCADE
+	);
+	`,
+	// maps goauth users to the voters they may vote for, a user votes for
+	// at most one voter in each revision
+	`
+	CREATE TABLE IF NOT EXISTS user_voters (
+		user_id BIGINT UNSIGNED NOT NULL,
+		voter_id BIGINT UNSIGNED NOT NULL,
+		revision_id BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (user_id, revision_id),
+		FOREIGN KEY (voter_id)
+			REFERENCES voters (id)
+			ON DELETE CASCADE,
+		FOREIGN KEY (revision_id)
+			REFERENCES voters_revisions (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS admins (
+		user_id BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (user_id)
+	);
+	`,
+}
+
+// sqliteSchema contains the statements to create all tables in SQLite, see
+// mysqlSchema.
+// SQLite only creates auto increment ids for INTEGER PRIMARY KEY columns,
+// the other column types are the same as in mysqlSchema, SQLite maps them
+// to its own storage classes.
+var sqliteSchema = []string{
+	`
+	CREATE TABLE IF NOT EXISTS categories (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(150) NOT NULL,
+		created DATETIME NOT NULL,
+		CONSTRAINT name_unique UNIQUE (name)
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voters_revisions (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		category_id BIGINT UNSIGNED NOT NULL,
+		created DATETIME NOT NULL,
+		FOREIGN KEY (category_id)
+			REFERENCES categories (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voters (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		revision_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		weight INT,
+		CONSTRAINT name_unique UNIQUE (revision_id, name),
+		FOREIGN KEY (revision_id)
+			REFERENCES voters_revisions (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voting_collections (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		voters_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		voting_day DATETIME,
+		CONSTRAINT name_unique UNIQUE (name),
+		FOREIGN KEY (voters_id)
+			REFERENCES voters_revisions (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS voting_groups (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		collection_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		CONSTRAINT name_unique UNIQUE (collection_id, name),
+		FOREIGN KEY (collection_id)
+			REFERENCES voting_collections (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS median_votings (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		group_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		max_value INT,
+		percent_required DOUBLE,
+		CONSTRAINT name_unique UNIQUE (group_id, name),
+		FOREIGN KEY (group_id)
+			REFERENCES voting_groups (id)
+			ON DELETE CASCADE
+	);
+	`,
+	// name should be unique in both tables
+	// but we cannot enforce this
+	`
+	CREATE TABLE IF NOT EXISTS schulze_votings (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		group_id BIGINT UNSIGNED NOT NULL,
+		name VARCHAR(150),
+		percent_required DOUBLE,
+		CONSTRAINT name_unique UNIQUE (group_id, name),
+		FOREIGN KEY (group_id)
+			REFERENCES voting_groups (id)
+			ON DELETE CASCADE
+	);
+	`,
+	// SQLite accepts the MySQL quotes for the option column
+	`
+	CREATE TABLE IF NOT EXISTS schulze_options (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		voting_id BIGINT UNSIGNED NOT NULL,
+		` + "`option`" + ` VARCHAR(150),
+		CONSTRAINT option_unique UNIQUE (voting_id, ` + "`option`" + `),
+		FOREIGN KEY (voting_id)
+			REFERENCES schulze_votings (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS median_votes (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		voting_id BIGINT UNSIGNED NOT NULL,
+		voter_id BIGINT UNSIGNED NOT NULL,
+		value INT,
+		CONSTRAINT vote_unique UNIQUE (voting_id, voter_id),
+		FOREIGN KEY (voting_id)
+			REFERENCES median_votings (id)
+			ON DELETE CASCADE,
+		FOREIGN KEY (voter_id)
+			REFERENCES voters (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS schulze_votes (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		option_id BIGINT UNSIGNED NOT NULL,
+		voter_id BIGINT UNSIGNED NOT NULL,
+		sorting_position INT,
+		CONSTRAINT option_vote_unique UNIQUE (option_id, voter_id),
+		FOREIGN KEY (option_id)
+			REFERENCES schulze_options (id)
+			ON DELETE CASCADE,
+		FOREIGN KEY (voter_id)
+			REFERENCES voters (id)
+			ON DELETE CASCADE
+	);
+	`,
+	// maps goauth users to the voters they may vote for, a user votes for
+	// at most one voter in each revision
+	`
+	CREATE TABLE IF NOT EXISTS user_voters (
+		user_id BIGINT UNSIGNED NOT NULL,
+		voter_id BIGINT UNSIGNED NOT NULL,
+		revision_id BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (user_id, revision_id),
+		FOREIGN KEY (voter_id)
+			REFERENCES voters (id)
+			ON DELETE CASCADE,
+		FOREIGN KEY (revision_id)
+			REFERENCES voters_revisions (id)
+			ON DELETE CASCADE
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS admins (
+		user_id BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (user_id)
+	);
+	`,
+}
+
+// SQLStorage is the Storage that keeps all data in a MySQL or SQLite
+// database.
+type SQLStorage struct {
+	DB      *sql.DB
+	Dialect SQLDialect
+	// Logger is used to report errors that can't be returned, for example
+	// if a rollback fails.
+	Logger *logrus.Logger
+}
+
+// NewSQLStorage returns a new storage using db, Migrate must be called before
+// the storage is used with a new database.
+func NewSQLStorage(db *sql.DB, dialect SQLDialect, logger *logrus.Logger) *SQLStorage {
+	return &SQLStorage{DB: db, Dialect: dialect, Logger: logger}
 }
 
-func InsertCategory(context *VotingContext, name string) error {
+// InsertCategory inserts a new category and returns its id.
+func (storage *SQLStorage) InsertCategory(name string) (uint, error) {
 	now := Now()
 	query := "INSERT INTO categories (name, created) VALUES (?, ?);"
-	_, err := context.DB.Exec(query, name, now)
-	return err
+	res, err := storage.DB.Exec(query, name, now)
+	if err != nil {
+		return InvalidID, err
+	}
+	return lastInsertID(res)
 }
 
-func ListCategories(context *VotingContext) ([]*Category, error) {
+func (storage *SQLStorage) ListCategories() ([]*Category, error) {
 	query := "SELECT id, name, created FROM categories ORDER BY created"
-	rows, err := context.DB.Query(query)
+	rows, err := storage.DB.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +435,7 @@ func ListCategories(context *VotingContext) ([]*Category, error) {
 	for rows.Next() {
 		var id uint
 		var name string
-		var createdStr []byte
+		var createdStr interface{}
 		scanErr := rows.Scan(&id, &name, &createdStr)
 		if scanErr != nil {
 			return nil, scanErr
@@ -227,21 +453,26 @@ func ListCategories(context *VotingContext) ([]*Category, error) {
 	return res, nil
 }
 
-func InsertVotersRevision(context *VotingContext, categoryID uint) error {
+// InsertVotersRevision inserts a new (empty) revision in the category with
+// id categoryID and returns the id of the new revision.
+func (storage *SQLStorage) InsertVotersRevision(categoryID uint) (uint, error) {
 	now := Now()
 	query := "INSERT INTO voters_revisions (category_id, created) VALUES (?, ?);"
-	_, err := context.DB.Exec(query, categoryID, now)
-	return err
+	res, err := storage.DB.Exec(query, categoryID, now)
+	if err != nil {
+		return InvalidID, err
+	}
+	return lastInsertID(res)
 }
 
-func ListVotersRevision(context *VotingContext, categoryID uint) ([]*VotersRevision, error) {
+func (storage *SQLStorage) ListVotersRevision(categoryID uint) ([]*VotersRevision, error) {
 	query := "SELECT id, category_id, created FROM voters_revisions ORDER BY created"
 	args := make([]interface{}, 0)
 	if categoryID != InvalidID {
 		query = "SELECT id, category_id, created FROM voters_revisions WHERE category_id = ? ORDER BY created"
 		args = append(args, categoryID)
 	}
-	rows, err := context.DB.Query(query, args...)
+	rows, err := storage.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -249,7 +480,7 @@ func ListVotersRevision(context *VotingContext, categoryID uint) ([]*VotersRevis
 	res := make([]*VotersRevision, 0)
 	for rows.Next() {
 		var id, cID uint
-		var createdStr []byte
+		var createdStr interface{}
 		scanErr := rows.Scan(&id, &cID, &createdStr)
 		if scanErr != nil {
 			return nil, scanErr
@@ -267,44 +498,122 @@ func ListVotersRevision(context *VotingContext, categoryID uint) ([]*VotersRevis
 	return res, nil
 }
 
-func InsertVoters(context *VotingContext, revisionID uint, voters []*Voter) error {
-	tx, err := context.DB.Begin()
+// InsertVoters inserts all voters into the revision with id revisionID.
+// The links between users and voters from the previous revision in the
+// same category are carried forward to voters with the same name.
+func (storage *SQLStorage) InsertVoters(revisionID uint, voters []*Voter) error {
+	tx, err := storage.DB.Begin()
 	if err != nil {
 		return err
 	}
+	previousID, err := previousRevisionTx(tx, revisionID)
+	if err == nil {
+		err = insertVotersTx(tx, storage.Dialect, revisionID, voters, previousID)
+	}
+	if err == nil {
+		return tx.Commit()
+	} else {
+		rollBackErr := tx.Rollback()
+		if rollBackErr != nil {
+			storage.Logger.WithError(rollBackErr).Error("Error while using Rollback in InsertVotersRevision")
+		}
+		return err
+	}
+}
+
+// insertVotersTx inserts the voters and carries forward the user links from
+// the revision with id sourceID, no links are carried forward if sourceID is
+// InvalidID.
+func insertVotersTx(tx *sql.Tx, dialect SQLDialect, revisionID uint, voters []*Voter, sourceID uint) error {
 	query := "INSERT INTO voters (revision_id, name, weight) VALUES (?, ?, ?);"
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 	defer stmt.Close()
-	err = nil
 	for _, voter := range voters {
-		_, err = stmt.Exec(revisionID, voter.Name, voter.Weight)
-		if err != nil {
-			break
+		if _, err = stmt.Exec(revisionID, voter.Name, voter.Weight); err != nil {
+			return err
 		}
 	}
+	if sourceID == InvalidID {
+		return nil
+	}
+	return carryForwardUserVotersTx(tx, dialect, sourceID, revisionID)
+}
+
+// CloneVotersRevision creates a new revision in the category of the
+// revision with id revisionID. The voters of the revision are copied to the
+// new revision after applying diff. It returns the id of the new revision.
+// The links between users and voters of the cloned revision are carried
+// forward to voters with the same name.
+func (storage *SQLStorage) CloneVotersRevision(revisionID uint, diff *VotersDiff) (uint, error) {
+	tx, err := storage.DB.Begin()
+	if err != nil {
+		return InvalidID, err
+	}
+	newID, err := cloneVotersRevisionTx(tx, storage.Dialect, revisionID, diff)
 	if err == nil {
-		return tx.Commit()
+		return newID, tx.Commit()
 	} else {
 		rollBackErr := tx.Rollback()
 		if rollBackErr != nil {
-			context.Logger.WithError(rollBackErr).Error("Error while using Rollback in InsertVotersRevision")
+			storage.Logger.WithError(rollBackErr).Error("Error while using Rollback in CloneVotersRevision")
 		}
-		return err
+		return InvalidID, err
+	}
+}
+
+func cloneVotersRevisionTx(tx *sql.Tx, dialect SQLDialect, revisionID uint, diff *VotersDiff) (uint, error) {
+	var categoryID uint
+	err := tx.QueryRow("SELECT category_id FROM voters_revisions WHERE id = ?;", revisionID).Scan(&categoryID)
+	if err != nil {
+		return InvalidID, err
+	}
+	rows, err := tx.Query("SELECT name, weight FROM voters WHERE revision_id = ? ORDER BY name", revisionID)
+	if err != nil {
+		return InvalidID, err
+	}
+	defer rows.Close()
+	voters := make([]*Voter, 0)
+	for rows.Next() {
+		var name string
+		var weight int
+		if scanErr := rows.Scan(&name, &weight); scanErr != nil {
+			return InvalidID, scanErr
+		}
+		voters = append(voters, NewVoter(name, weight))
+	}
+	if err = rows.Err(); err != nil {
+		return InvalidID, err
+	}
+	rows.Close()
+	newVoters, err := diff.Apply(voters)
+	if err != nil {
+		return InvalidID, err
 	}
+	res, err := tx.Exec("INSERT INTO voters_revisions (category_id, created) VALUES (?, ?);", categoryID, Now())
+	if err != nil {
+		return InvalidID, err
+	}
+	newID, err := lastInsertID(res)
+	if err != nil {
+		return InvalidID, err
+	}
+	if err = insertVotersTx(tx, dialect, newID, newVoters, revisionID); err != nil {
+		return InvalidID, err
+	}
+	return newID, nil
 }
 
-func ListVoters(context *VotingContext, revisionID uint) ([]*Voter, error) {
+func (storage *SQLStorage) ListVoters(revisionID uint) ([]*Voter, error) {
 	query := "SELECT id, revision_id, name, weight FROM voters ORDER BY name"
 	args := make([]interface{}, 0)
 	if revisionID != InvalidID {
 		query = "SELECT id, revision_id, name, weight FROM voters WHERE revision_id = ? ORDER BY name"
 		args = append(args, revisionID)
 	}
-	rows, err := context.DB.Query(query, args...)
+	rows, err := storage.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -325,4 +634,857 @@ func ListVoters(context *VotingContext, revisionID uint) ([]*Voter, error) {
 		return nil, err
 	}
 	return res, nil
-}
\ No newline at end of file
+}
+
+// InsertVotingCollection inserts the collection and all its groups and
+// votings in a single transaction.
+// The collection is linked to the voters revision with id votersID.
+// On success all ID fields in the collection are set to the ids from the
+// database.
+func (storage *SQLStorage) InsertVotingCollection(votersID uint, collection *VotingCollection) error {
+	tx, err := storage.DB.Begin()
+	if err != nil {
+		return err
+	}
+	err = insertVotingCollectionTx(tx, votersID, collection)
+	if err == nil {
+		return tx.Commit()
+	} else {
+		rollBackErr := tx.Rollback()
+		if rollBackErr != nil {
+			storage.Logger.WithError(rollBackErr).Error("Error while using Rollback in InsertVotingCollection")
+		}
+		return err
+	}
+}
+
+func lastInsertID(res sql.Result) (uint, error) {
+	id, err := res.LastInsertId()
+	if err != nil {
+		return InvalidID, err
+	}
+	return uint(id), nil
+}
+
+func insertVotingCollectionTx(tx *sql.Tx, votersID uint, collection *VotingCollection) error {
+	query := "INSERT INTO voting_collections (voters_id, name, voting_day) VALUES (?, ?, ?);"
+	res, err := tx.Exec(query, votersID, collection.Name, collection.Date)
+	if err != nil {
+		return err
+	}
+	collectionID, err := lastInsertID(res)
+	if err != nil {
+		return err
+	}
+	groupStmt, err := tx.Prepare("INSERT INTO voting_groups (collection_id, name) VALUES (?, ?);")
+	if err != nil {
+		return err
+	}
+	defer groupStmt.Close()
+	medianStmt, err := tx.Prepare("INSERT INTO median_votings (group_id, name, max_value, percent_required, position) VALUES (?, ?, ?, ?, ?);")
+	if err != nil {
+		return err
+	}
+	defer medianStmt.Close()
+	schulzeStmt, err := tx.Prepare("INSERT INTO schulze_votings (group_id, name, percent_required, position, status_quo) VALUES (?, ?, ?, ?, ?);")
+	if err != nil {
+		return err
+	}
+	defer schulzeStmt.Close()
+	optionStmt, err := tx.Prepare("INSERT INTO schulze_options (voting_id, `option`) VALUES (?, ?);")
+	if err != nil {
+		return err
+	}
+	defer optionStmt.Close()
+	for _, group := range collection.Groups {
+		res, err = groupStmt.Exec(collectionID, group.Name)
+		if err != nil {
+			return err
+		}
+		groupID, err := lastInsertID(res)
+		if err != nil {
+			return err
+		}
+		group.NormalizePositions()
+		for _, voting := range group.MedianVotings {
+			res, err = medianStmt.Exec(groupID, voting.Name, voting.MaxValue, voting.PercentRequired, voting.Position)
+			if err != nil {
+				return err
+			}
+			votingID, err := lastInsertID(res)
+			if err != nil {
+				return err
+			}
+			voting.ID, voting.GroupID = votingID, groupID
+		}
+		for _, voting := range group.SchulzeVotings {
+			res, err = schulzeStmt.Exec(groupID, voting.Name, voting.PercentRequired, voting.Position, voting.StatusQuo)
+			if err != nil {
+				return err
+			}
+			votingID, err := lastInsertID(res)
+			if err != nil {
+				return err
+			}
+			optionIDs := make([]uint, len(voting.Options))
+			for i, option := range voting.Options {
+				res, err = optionStmt.Exec(votingID, option)
+				if err != nil {
+					return err
+				}
+				optionIDs[i], err = lastInsertID(res)
+				if err != nil {
+					return err
+				}
+			}
+			voting.ID, voting.GroupID, voting.OptionIDs = votingID, groupID, optionIDs
+		}
+		group.ID, group.CollectionID = groupID, collectionID
+	}
+	collection.ID, collection.VotersID = collectionID, votersID
+	return nil
+}
+
+// ListVotingCollections lists all voting collections for the voters revision
+// with the given id (or all collections if votersID is InvalidID).
+// The collections returned don't contain any groups, use GetVotingCollection
+// to retrieve the whole collection.
+func (storage *SQLStorage) ListVotingCollections(votersID uint) ([]*VotingCollection, error) {
+	query := "SELECT id, voters_id, name, voting_day FROM voting_collections ORDER BY voting_day"
+	args := make([]interface{}, 0)
+	if votersID != InvalidID {
+		query = "SELECT id, voters_id, name, voting_day FROM voting_collections WHERE voters_id = ? ORDER BY voting_day"
+		args = append(args, votersID)
+	}
+	rows, err := storage.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*VotingCollection, 0)
+	for rows.Next() {
+		var id, vID uint
+		var name string
+		var dateStr interface{}
+		scanErr := rows.Scan(&id, &vID, &name, &dateStr)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		date, timeErr := TimeFromScanType(dateStr)
+		if timeErr != nil {
+			return nil, timeErr
+		}
+		res = append(res, &VotingCollection{Name: name, Date: date,
+			Groups: make([]*VotingGroup, 0), ID: id, VotersID: vID})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListUserCollections lists all voting collections the user with id userID
+// may vote in, i.e. all collections linked to a revision in which the user
+// is linked to a voter.
+// As in ListVotingCollections the collections don't contain any groups.
+func (storage *SQLStorage) ListUserCollections(userID uint64) ([]*VotingCollection, error) {
+	query := `SELECT c.id, c.voters_id, c.name, c.voting_day FROM voting_collections c
+	JOIN user_voters u ON c.voters_id = u.revision_id
+	WHERE u.user_id = ? ORDER BY c.voting_day`
+	rows, err := storage.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*VotingCollection, 0)
+	for rows.Next() {
+		var id, vID uint
+		var name string
+		var dateStr interface{}
+		scanErr := rows.Scan(&id, &vID, &name, &dateStr)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		date, timeErr := TimeFromScanType(dateStr)
+		if timeErr != nil {
+			return nil, timeErr
+		}
+		res = append(res, &VotingCollection{Name: name, Date: date,
+			Groups: make([]*VotingGroup, 0), ID: id, VotersID: vID})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetVotingCollection returns the collection with the given id, including
+// all groups, votings and options.
+// If there is no such collection it returns sql.ErrNoRows.
+func (storage *SQLStorage) GetVotingCollection(id uint) (*VotingCollection, error) {
+	query := "SELECT voters_id, name, voting_day FROM voting_collections WHERE id = ?;"
+	row := storage.DB.QueryRow(query, id)
+	var votersID uint
+	var name string
+	var dateStr interface{}
+	if err := row.Scan(&votersID, &name, &dateStr); err != nil {
+		return nil, err
+	}
+	date, timeErr := TimeFromScanType(dateStr)
+	if timeErr != nil {
+		return nil, timeErr
+	}
+	res := &VotingCollection{Name: name, Date: date,
+		Groups: make([]*VotingGroup, 0), ID: id, VotersID: votersID}
+	groups, err := storage.listVotingGroups(id)
+	if err != nil {
+		return nil, err
+	}
+	// map group ids to the groups to add the votings
+	groupMap := make(map[uint]*VotingGroup, len(groups))
+	for _, group := range groups {
+		groupMap[group.ID] = group
+	}
+	res.Groups = groups
+	if err = storage.addMedianVotings(id, groupMap); err != nil {
+		return nil, err
+	}
+	if err = storage.addSchulzeVotings(id, groupMap); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (storage *SQLStorage) listVotingGroups(collectionID uint) ([]*VotingGroup, error) {
+	query := "SELECT id, name FROM voting_groups WHERE collection_id = ? ORDER BY id"
+	rows, err := storage.DB.Query(query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*VotingGroup, 0)
+	for rows.Next() {
+		var id uint
+		var name string
+		scanErr := rows.Scan(&id, &name)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		res = append(res, &VotingGroup{Name: name,
+			MedianVotings:  make([]*MedianVoting, 0),
+			SchulzeVotings: make([]*SchulzeVoting, 0),
+			ID:             id, CollectionID: collectionID})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (storage *SQLStorage) addMedianVotings(collectionID uint, groups map[uint]*VotingGroup) error {
+	query := `SELECT m.id, m.group_id, m.name, m.max_value, m.percent_required, m.position
+	FROM median_votings m JOIN voting_groups g ON m.group_id = g.id
+	WHERE g.collection_id = ? ORDER BY m.position, m.id`
+	rows, err := storage.DB.Query(query, collectionID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, groupID uint
+		var name string
+		var maxValue, position int
+		var percentRequired float64
+		scanErr := rows.Scan(&id, &groupID, &name, &maxValue, &percentRequired, &position)
+		if scanErr != nil {
+			return scanErr
+		}
+		group, has := groups[groupID]
+		if !has {
+			return fmt.Errorf("Median voting %d references unknown group %d", id, groupID)
+		}
+		group.MedianVotings = append(group.MedianVotings, &MedianVoting{Name: name,
+			MaxValue: maxValue, PercentRequired: percentRequired,
+			Position: position, ID: id, GroupID: groupID})
+	}
+	return rows.Err()
+}
+
+func (storage *SQLStorage) addSchulzeVotings(collectionID uint, groups map[uint]*VotingGroup) error {
+	query := `SELECT s.id, s.group_id, s.name, s.percent_required, s.position, s.status_quo
+	FROM schulze_votings s JOIN voting_groups g ON s.group_id = g.id
+	WHERE g.collection_id = ? ORDER BY s.position, s.id`
+	rows, err := storage.DB.Query(query, collectionID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	votings := make(map[uint]*SchulzeVoting)
+	for rows.Next() {
+		var id, groupID uint
+		var name, statusQuo string
+		var position int
+		var percentRequired float64
+		scanErr := rows.Scan(&id, &groupID, &name, &percentRequired, &position, &statusQuo)
+		if scanErr != nil {
+			return scanErr
+		}
+		group, has := groups[groupID]
+		if !has {
+			return fmt.Errorf("Schulze voting %d references unknown group %d", id, groupID)
+		}
+		voting := &SchulzeVoting{Name: name, Options: make([]string, 0),
+			PercentRequired: percentRequired, OptionIDs: make([]uint, 0),
+			StatusQuo: statusQuo, Position: position, ID: id, GroupID: groupID}
+		group.SchulzeVotings = append(group.SchulzeVotings, voting)
+		votings[id] = voting
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return storage.addSchulzeOptions(collectionID, votings)
+}
+
+func (storage *SQLStorage) addSchulzeOptions(collectionID uint, votings map[uint]*SchulzeVoting) error {
+	query := "SELECT o.id, o.voting_id, o.`option`" + `
+	FROM schulze_options o JOIN schulze_votings s ON o.voting_id = s.id
+	JOIN voting_groups g ON s.group_id = g.id
+	WHERE g.collection_id = ? ORDER BY o.id`
+	rows, err := storage.DB.Query(query, collectionID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, votingID uint
+		var option string
+		scanErr := rows.Scan(&id, &votingID, &option)
+		if scanErr != nil {
+			return scanErr
+		}
+		voting, has := votings[votingID]
+		if !has {
+			return fmt.Errorf("Schulze option %d references unknown voting %d", id, votingID)
+		}
+		voting.Options = append(voting.Options, option)
+		voting.OptionIDs = append(voting.OptionIDs, id)
+	}
+	return rows.Err()
+}
+
+// InsertMedianVote stores the value the voter with id voterID voted for in
+// the median voting with id votingID. If the voter abstains the value
+// stored is 0.
+func (storage *SQLStorage) InsertMedianVote(votingID, voterID uint, value int, abstain bool) error {
+	query := "INSERT INTO median_votes (voting_id, voter_id, value, abstain) VALUES (?, ?, ?, ?);"
+	_, err := storage.DB.Exec(query, votingID, voterID, medianStoredValue(value, abstain), abstain)
+	return err
+}
+
+// UpdateMedianVote updates an existing vote created with InsertMedianVote, it
+// returns sql.ErrNoRows if there is no such vote.
+func (storage *SQLStorage) UpdateMedianVote(votingID, voterID uint, value int, abstain bool) error {
+	query := "UPDATE median_votes SET value = ?, abstain = ? WHERE voting_id = ? AND voter_id = ?;"
+	res, err := storage.DB.Exec(query, medianStoredValue(value, abstain), abstain, votingID, voterID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// MySQL doesn't count rows that are unchanged, so check if the vote
+		// exists
+		_, _, err = storage.GetMedianVote(votingID, voterID)
+	}
+	return err
+}
+
+// GetMedianVotes returns all votes for the median voting with id votingID.
+// The weight of each vote is the weight of the voter who cast it, so the
+// result can be used directly in EvaluateMedian.
+// Only votes from voters of the revision the collection is linked to are
+// returned.
+func (storage *SQLStorage) GetMedianVotes(votingID uint) ([]*MedianVote, error) {
+	query := `SELECT v.name, v.weight, m.value, m.abstain FROM median_votes m
+	JOIN voters v ON m.voter_id = v.id
+	JOIN median_votings mv ON m.voting_id = mv.id
+	JOIN voting_groups g ON mv.group_id = g.id
+	JOIN voting_collections c ON g.collection_id = c.id
+	WHERE m.voting_id = ? AND v.revision_id = c.voters_id ORDER BY m.id`
+	rows, err := storage.DB.Query(query, votingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*MedianVote, 0)
+	for rows.Next() {
+		var name string
+		var weight, value int
+		var abstain bool
+		scanErr := rows.Scan(&name, &weight, &value, &abstain)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		res = append(res, &MedianVote{Weight: weight, Value: value, Voter: name, Abstain: abstain})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getSchulzeOptionIDs returns the ids of all options for the schulze voting
+// with id votingID in the order of the options in the voting.
+func getSchulzeOptionIDs(q queryer, votingID uint) ([]uint, error) {
+	query := "SELECT id FROM schulze_options WHERE voting_id = ? ORDER BY id"
+	rows, err := q.Query(query, votingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]uint, 0)
+	for rows.Next() {
+		var id uint
+		scanErr := rows.Scan(&id)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		res = append(res, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// InsertSchulzeVote stores the ranking of the voter with id voterID for the
+// schulze voting with id votingID. ranking is a ranking as described in
+// SchulzeVote and must contain an entry for each option of the voting.
+// One entry in schulze_votes is created for each option, the
+// sorting_position of an Unranked option is NULL. A ranking in which no
+// option is ranked is an abstention (see RankingAbstains).
+func (storage *SQLStorage) InsertSchulzeVote(votingID, voterID uint, ranking []int) error {
+	tx, err := storage.DB.Begin()
+	if err != nil {
+		return err
+	}
+	err = insertSchulzeVoteTx(tx, votingID, voterID, ranking)
+	if err == nil {
+		return tx.Commit()
+	} else {
+		rollBackErr := tx.Rollback()
+		if rollBackErr != nil {
+			storage.Logger.WithError(rollBackErr).Error("Error while using Rollback in InsertSchulzeVote")
+		}
+		return err
+	}
+}
+
+// UpdateSchulzeVote replaces the ranking of the voter with id voterID for the
+// schulze voting with id votingID by a new ranking.
+func (storage *SQLStorage) UpdateSchulzeVote(votingID, voterID uint, ranking []int) error {
+	tx, err := storage.DB.Begin()
+	if err != nil {
+		return err
+	}
+	err = deleteSchulzeVoteTx(tx, votingID, voterID)
+	if err == nil {
+		err = insertSchulzeVoteTx(tx, votingID, voterID, ranking)
+	}
+	if err == nil {
+		return tx.Commit()
+	} else {
+		rollBackErr := tx.Rollback()
+		if rollBackErr != nil {
+			storage.Logger.WithError(rollBackErr).Error("Error while using Rollback in UpdateSchulzeVote")
+		}
+		return err
+	}
+}
+
+func insertSchulzeVoteTx(tx *sql.Tx, votingID, voterID uint, ranking []int) error {
+	optionIDs, err := getSchulzeOptionIDs(tx, votingID)
+	if err != nil {
+		return err
+	}
+	if len(optionIDs) != len(ranking) {
+		return fmt.Errorf("Expected ranking of length %d, got length %d", len(optionIDs), len(ranking))
+	}
+	stmt, err := tx.Prepare("INSERT INTO schulze_votes (option_id, voter_id, sorting_position) VALUES (?, ?, ?);")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i, optionID := range optionIDs {
+		position := sql.NullInt64{Int64: int64(ranking[i]), Valid: ranking[i] != Unranked}
+		if _, err = stmt.Exec(optionID, voterID, position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullPosition returns the position of an option stored in schulze_votes,
+// NULL is Unranked.
+func nullPosition(position sql.NullInt64) int {
+	if !position.Valid {
+		return Unranked
+	}
+	return int(position.Int64)
+}
+
+func deleteSchulzeVoteTx(tx *sql.Tx, votingID, voterID uint) error {
+	query := `DELETE FROM schulze_votes WHERE voter_id = ? AND option_id IN
+	(SELECT id FROM schulze_options WHERE voting_id = ?);`
+	_, err := tx.Exec(query, voterID, votingID)
+	return err
+}
+
+// GetSchulzeVotes returns all votes for the schulze voting with id votingID.
+// The weight of each vote is the weight of the voter who cast it and
+// Ranking[i] is the position of the i-th option of the voting, so the result
+// can be used directly in EvaluateSchulze. A vote in which no option is
+// ranked is returned as an abstention.
+// As in GetMedianVotes only voters from the linked revision are considered.
+func (storage *SQLStorage) GetSchulzeVotes(votingID uint) ([]*SchulzeVote, error) {
+	optionIDs, err := getSchulzeOptionIDs(storage.DB, votingID)
+	if err != nil {
+		return nil, err
+	}
+	n := len(optionIDs)
+	// maps the option id to the position in the ranking
+	optionPositions := make(map[uint]int, n)
+	for i, id := range optionIDs {
+		optionPositions[id] = i
+	}
+	query := `SELECT s.voter_id, v.weight, s.option_id, s.sorting_position
+	FROM schulze_votes s JOIN schulze_options o ON s.option_id = o.id
+	JOIN voters v ON s.voter_id = v.id
+	JOIN schulze_votings sv ON o.voting_id = sv.id
+	JOIN voting_groups g ON sv.group_id = g.id
+	JOIN voting_collections c ON g.collection_id = c.id
+	WHERE o.voting_id = ? AND v.revision_id = c.voters_id ORDER BY s.voter_id`
+	rows, err := storage.DB.Query(query, votingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*SchulzeVote, 0)
+	// counts how many options have been ranked by the last voter
+	ranked := 0
+	lastVoter := InvalidID
+	var lastVote *SchulzeVote
+	for rows.Next() {
+		var voterID, optionID uint
+		var weight int
+		var position sql.NullInt64
+		scanErr := rows.Scan(&voterID, &weight, &optionID, &position)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		if voterID != lastVoter {
+			if lastVote != nil && ranked != n {
+				return nil, fmt.Errorf("Voter %d ranked %d options, expected %d", lastVoter, ranked, n)
+			}
+			lastVote = NewSchulzeVote(weight, make([]int, n))
+			res = append(res, lastVote)
+			lastVoter = voterID
+			ranked = 0
+		}
+		lastVote.Ranking[optionPositions[optionID]] = nullPosition(position)
+		ranked++
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if lastVote != nil && ranked != n {
+		return nil, fmt.Errorf("Voter %d ranked %d options, expected %d", lastVoter, ranked, n)
+	}
+	for _, vote := range res {
+		if RankingAbstains(vote.Ranking) {
+			vote.Abstain = true
+		}
+	}
+	return res, nil
+}
+
+// GetMedianVoting returns the median voting with the given id.
+// If there is no such voting it returns sql.ErrNoRows.
+func (storage *SQLStorage) GetMedianVoting(id uint) (*MedianVoting, error) {
+	query := "SELECT group_id, name, max_value, percent_required, position FROM median_votings WHERE id = ?;"
+	row := storage.DB.QueryRow(query, id)
+	var groupID uint
+	var name string
+	var maxValue, position int
+	var percentRequired float64
+	if err := row.Scan(&groupID, &name, &maxValue, &percentRequired, &position); err != nil {
+		return nil, err
+	}
+	return &MedianVoting{Name: name, MaxValue: maxValue,
+		PercentRequired: percentRequired, Position: position, ID: id, GroupID: groupID}, nil
+}
+
+// GetSchulzeVoting returns the schulze voting with the given id, including
+// all options.
+// If there is no such voting it returns sql.ErrNoRows.
+func (storage *SQLStorage) GetSchulzeVoting(id uint) (*SchulzeVoting, error) {
+	query := "SELECT group_id, name, percent_required, position, status_quo FROM schulze_votings WHERE id = ?;"
+	row := storage.DB.QueryRow(query, id)
+	var groupID uint
+	var name, statusQuo string
+	var position int
+	var percentRequired float64
+	if err := row.Scan(&groupID, &name, &percentRequired, &position, &statusQuo); err != nil {
+		return nil, err
+	}
+	res := &SchulzeVoting{Name: name, Options: make([]string, 0),
+		PercentRequired: percentRequired, OptionIDs: make([]uint, 0),
+		StatusQuo: statusQuo, Position: position, ID: id, GroupID: groupID}
+	rows, err := storage.DB.Query("SELECT id, `option` FROM schulze_options WHERE voting_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var optionID uint
+		var option string
+		scanErr := rows.Scan(&optionID, &option)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		res.Options = append(res.Options, option)
+		res.OptionIDs = append(res.OptionIDs, optionID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetVoterByName returns the voter with the given name in the revision with
+// id revisionID.
+// If there is no such voter it returns sql.ErrNoRows.
+func (storage *SQLStorage) GetVoterByName(revisionID uint, name string) (*Voter, error) {
+	query := "SELECT id, weight FROM voters WHERE revision_id = ? AND name = ?;"
+	row := storage.DB.QueryRow(query, revisionID, name)
+	var id uint
+	var weight int
+	if err := row.Scan(&id, &weight); err != nil {
+		return nil, err
+	}
+	return &Voter{Name: name, Weight: weight, ID: id, RevisionID: revisionID}, nil
+}
+
+// GetMedianVotingRevision returns the id of the voters revision the
+// collection containing the median voting with id votingID is linked to.
+func (storage *SQLStorage) GetMedianVotingRevision(votingID uint) (uint, error) {
+	query := `SELECT c.voters_id FROM median_votings m
+	JOIN voting_groups g ON m.group_id = g.id
+	JOIN voting_collections c ON g.collection_id = c.id
+	WHERE m.id = ?;`
+	var res uint
+	err := storage.DB.QueryRow(query, votingID).Scan(&res)
+	return res, err
+}
+
+// GetSchulzeVotingRevision returns the id of the voters revision the
+// collection containing the schulze voting with id votingID is linked to.
+func (storage *SQLStorage) GetSchulzeVotingRevision(votingID uint) (uint, error) {
+	query := `SELECT c.voters_id FROM schulze_votings s
+	JOIN voting_groups g ON s.group_id = g.id
+	JOIN voting_collections c ON g.collection_id = c.id
+	WHERE s.id = ?;`
+	var res uint
+	err := storage.DB.QueryRow(query, votingID).Scan(&res)
+	return res, err
+}
+
+// GetMedianVote returns the value the voter with id voterID voted for in the
+// median voting with id votingID and if the voter abstained.
+// If the voter hasn't voted yet it returns sql.ErrNoRows.
+func (storage *SQLStorage) GetMedianVote(votingID, voterID uint) (int, bool, error) {
+	query := "SELECT value, abstain FROM median_votes WHERE voting_id = ? AND voter_id = ?;"
+	var value int
+	var abstain bool
+	err := storage.DB.QueryRow(query, votingID, voterID).Scan(&value, &abstain)
+	return value, abstain, err
+}
+
+// GetSchulzeVote returns the ranking of the voter with id voterID for the
+// schulze voting with id votingID.
+// If the voter hasn't voted yet it returns sql.ErrNoRows.
+func (storage *SQLStorage) GetSchulzeVote(votingID, voterID uint) ([]int, error) {
+	optionIDs, err := getSchulzeOptionIDs(storage.DB, votingID)
+	if err != nil {
+		return nil, err
+	}
+	optionPositions := make(map[uint]int, len(optionIDs))
+	for i, id := range optionIDs {
+		optionPositions[id] = i
+	}
+	query := `SELECT s.option_id, s.sorting_position
+	FROM schulze_votes s JOIN schulze_options o ON s.option_id = o.id
+	WHERE o.voting_id = ? AND s.voter_id = ?`
+	rows, err := storage.DB.Query(query, votingID, voterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]int, len(optionIDs))
+	found := 0
+	for rows.Next() {
+		var optionID uint
+		var position sql.NullInt64
+		scanErr := rows.Scan(&optionID, &position)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		res[optionPositions[optionID]] = nullPosition(position)
+		found++
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return res, nil
+}
+
+// previousRevisionTx returns the id of the revision created before the
+// revision with id revisionID in the same category, InvalidID if there is
+// none.
+func previousRevisionTx(tx *sql.Tx, revisionID uint) (uint, error) {
+	query := `SELECT p.id FROM voters_revisions p
+	JOIN voters_revisions r ON p.category_id = r.category_id
+	WHERE r.id = ? AND p.id < r.id ORDER BY p.id DESC LIMIT 1;`
+	var previousID uint
+	switch err := tx.QueryRow(query, revisionID).Scan(&previousID); {
+	case err == sql.ErrNoRows:
+		// first revision in the category
+		return InvalidID, nil
+	case err != nil:
+		return InvalidID, err
+	}
+	return previousID, nil
+}
+
+// carryForwardUserVotersTx links users to the voters of the revision with id
+// revisionID if they were linked to a voter with the same name in the
+// revision with id sourceID.
+// Users that are already linked to a voter in the revision are not changed.
+func carryForwardUserVotersTx(tx *sql.Tx, dialect SQLDialect, sourceID, revisionID uint) error {
+	query := dialect.insertIgnore() + ` INTO user_voters (user_id, voter_id, revision_id)
+	SELECT u.user_id, n.id, n.revision_id FROM user_voters u
+	JOIN voters o ON u.voter_id = o.id
+	JOIN voters n ON o.name = n.name
+	WHERE o.revision_id = ? AND n.revision_id = ?;`
+	_, err := tx.Exec(query, sourceID, revisionID)
+	return err
+}
+
+// LinkUserToVoter allows the user with id userID to vote for the voter with
+// id voterID. If the user was linked to another voter in the same revision
+// this link is replaced.
+func (storage *SQLStorage) LinkUserToVoter(userID uint64, voterID uint) error {
+	query := `REPLACE INTO user_voters (user_id, voter_id, revision_id)
+	SELECT ?, id, revision_id FROM voters WHERE id = ?;`
+	res, err := storage.DB.Exec(query, userID, voterID)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("There is no voter with id %d", voterID)
+	}
+	return nil
+}
+
+// UnlinkUser removes the link of the user with id userID to a voter in the
+// revision with id revisionID.
+func (storage *SQLStorage) UnlinkUser(userID uint64, revisionID uint) error {
+	query := "DELETE FROM user_voters WHERE user_id = ? AND revision_id = ?;"
+	_, err := storage.DB.Exec(query, userID, revisionID)
+	return err
+}
+
+// GetUserVoter returns the voter in the revision with id revisionID the user
+// with id userID may vote for.
+// If the user is not linked to a voter in the revision it returns
+// sql.ErrNoRows.
+func (storage *SQLStorage) GetUserVoter(userID uint64, revisionID uint) (*Voter, error) {
+	query := `SELECT v.id, v.name, v.weight FROM user_voters u
+	JOIN voters v ON u.voter_id = v.id
+	WHERE u.user_id = ? AND u.revision_id = ?;`
+	row := storage.DB.QueryRow(query, userID, revisionID)
+	var id uint
+	var name string
+	var weight int
+	if err := row.Scan(&id, &name, &weight); err != nil {
+		return nil, err
+	}
+	return &Voter{Name: name, Weight: weight, ID: id, RevisionID: revisionID}, nil
+}
+
+// ListVoterUsers returns the ids of all users linked to a voter in the
+// revision with id revisionID, the keys of the map are the voter ids.
+func (storage *SQLStorage) ListVoterUsers(revisionID uint) (map[uint][]uint64, error) {
+	query := "SELECT voter_id, user_id FROM user_voters WHERE revision_id = ? ORDER BY user_id"
+	rows, err := storage.DB.Query(query, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[uint][]uint64)
+	for rows.Next() {
+		var voterID uint
+		var userID uint64
+		scanErr := rows.Scan(&voterID, &userID)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		res[voterID] = append(res[voterID], userID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// IsAdmin returns true if the user with id userID is an administrator.
+func (storage *SQLStorage) IsAdmin(userID uint64) (bool, error) {
+	query := "SELECT COUNT(*) FROM admins WHERE user_id = ?;"
+	var count int
+	if err := storage.DB.QueryRow(query, userID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SetAdmin grants (admin = true) or revokes (admin = false) the
+// administrator role for the user with id userID.
+func (storage *SQLStorage) SetAdmin(userID uint64, admin bool) error {
+	var query string
+	if admin {
+		query = storage.Dialect.insertIgnore() + " INTO admins (user_id) VALUES (?);"
+	} else {
+		query = "DELETE FROM admins WHERE user_id = ?;"
+	}
+	_, err := storage.DB.Exec(query, userID)
+	return err
+}
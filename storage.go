@@ -0,0 +1,155 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+// Storage is used to store and retrieve categories, voters revisions,
+// voters, voting collections and votes.
+//
+// SQLStorage stores everything in a database, MemoryStorage keeps it in
+// memory and can be used for tests and if no database is available.
+//
+// Methods that return a single element return sql.ErrNoRows if there is no
+// such element, for both implementations.
+// Listing methods that accept an id of type uint list all elements if the id
+// is InvalidID.
+type Storage interface {
+	// InsertCategory inserts a new category and returns its id.
+	InsertCategory(name string) (uint, error)
+	// ListCategories lists all categories ordered by creation time.
+	ListCategories() ([]*Category, error)
+
+	// InsertVotersRevision inserts a new (empty) revision in the category
+	// with id categoryID and returns the id of the new revision.
+	InsertVotersRevision(categoryID uint) (uint, error)
+	// ListVotersRevision lists all revisions in the category with id
+	// categoryID ordered by creation time.
+	ListVotersRevision(categoryID uint) ([]*VotersRevision, error)
+
+	// InsertVoters inserts all voters into the revision with id revisionID.
+	// The links between users and voters from the previous revision in the
+	// same category are carried forward to voters with the same name.
+	InsertVoters(revisionID uint, voters []*Voter) error
+	// ListVoters lists all voters in the revision with id revisionID ordered
+	// by name.
+	ListVoters(revisionID uint) ([]*Voter, error)
+	// CloneVotersRevision creates a new revision in the category of the
+	// revision with id revisionID and copies the voters of the revision
+	// after applying diff. It returns the id of the new revision.
+	// The links between users and voters of the cloned revision are carried
+	// forward to voters with the same name.
+	CloneVotersRevision(revisionID uint, diff *VotersDiff) (uint, error)
+	// GetVoterByName returns the voter with the given name in the revision
+	// with id revisionID.
+	GetVoterByName(revisionID uint, name string) (*Voter, error)
+
+	// InsertVotingCollection inserts the collection and all its groups and
+	// votings, the collection is linked to the voters revision with id
+	// votersID. On success all ID fields in the collection are set and the
+	// positions of the votings are normalized with NormalizePositions.
+	// GetVotingCollection returns the votings of each group ordered by
+	// position.
+	InsertVotingCollection(votersID uint, collection *VotingCollection) error
+	// ListVotingCollections lists all collections for the voters revision
+	// with id votersID ordered by date, the collections don't contain any
+	// groups.
+	ListVotingCollections(votersID uint) ([]*VotingCollection, error)
+	// ListUserCollections lists all collections the user with id userID may
+	// vote in, as in ListVotingCollections the collections don't contain any
+	// groups.
+	ListUserCollections(userID uint64) ([]*VotingCollection, error)
+	// GetVotingCollection returns the collection with the given id, including
+	// all groups, votings and options.
+	GetVotingCollection(id uint) (*VotingCollection, error)
+
+	// GetMedianVoting returns the median voting with the given id.
+	GetMedianVoting(id uint) (*MedianVoting, error)
+	// GetSchulzeVoting returns the schulze voting with the given id,
+	// including all options.
+	GetSchulzeVoting(id uint) (*SchulzeVoting, error)
+	// GetMedianVotingRevision returns the id of the voters revision the
+	// collection containing the median voting is linked to.
+	GetMedianVotingRevision(votingID uint) (uint, error)
+	// GetSchulzeVotingRevision returns the id of the voters revision the
+	// collection containing the schulze voting is linked to.
+	GetSchulzeVotingRevision(votingID uint) (uint, error)
+
+	// InsertMedianVote stores the value the voter with id voterID voted for
+	// in the median voting with id votingID, value is ignored if the voter
+	// abstains.
+	InsertMedianVote(votingID, voterID uint, value int, abstain bool) error
+	// UpdateMedianVote updates an existing vote created with
+	// InsertMedianVote, it returns sql.ErrNoRows if there is no such vote.
+	UpdateMedianVote(votingID, voterID uint, value int, abstain bool) error
+	// GetMedianVote returns the value the voter voted for and if the voter
+	// abstained.
+	GetMedianVote(votingID, voterID uint) (int, bool, error)
+	// GetMedianVotes returns all votes from voters of the linked revision
+	// for the median voting with id votingID, weighted by the voter weights.
+	GetMedianVotes(votingID uint) ([]*MedianVote, error)
+
+	// InsertSchulzeVote stores the ranking of the voter with id voterID for
+	// the schulze voting with id votingID. Options may be Unranked, a
+	// ranking without ranked options is an abstention.
+	InsertSchulzeVote(votingID, voterID uint, ranking []int) error
+	// UpdateSchulzeVote replaces the ranking of the voter by a new ranking.
+	UpdateSchulzeVote(votingID, voterID uint, ranking []int) error
+	// GetSchulzeVote returns the ranking of the voter.
+	GetSchulzeVote(votingID, voterID uint) ([]int, error)
+	// GetSchulzeVotes returns all votes from voters of the linked revision
+	// for the schulze voting with id votingID, weighted by the voter weights.
+	// Votes without ranked options are returned as abstentions.
+	GetSchulzeVotes(votingID uint) ([]*SchulzeVote, error)
+
+	// LinkUserToVoter allows the user with id userID to vote for the voter
+	// with id voterID, replacing a link to another voter in the same
+	// revision.
+	LinkUserToVoter(userID uint64, voterID uint) error
+	// UnlinkUser removes the link of the user to a voter in the revision
+	// with id revisionID.
+	UnlinkUser(userID uint64, revisionID uint) error
+	// GetUserVoter returns the voter in the revision with id revisionID the
+	// user with id userID may vote for.
+	GetUserVoter(userID uint64, revisionID uint) (*Voter, error)
+	// ListVoterUsers returns the ids of all users linked to a voter in the
+	// revision with id revisionID, the keys of the map are the voter ids.
+	ListVoterUsers(revisionID uint) (map[uint][]uint64, error)
+
+	// IsAdmin returns true if the user with id userID is an administrator.
+	IsAdmin(userID uint64) (bool, error)
+	// SetAdmin grants or revokes the administrator role for the user.
+	SetAdmin(userID uint64, admin bool) error
+}
+
+var (
+	_ Storage = (*SQLStorage)(nil)
+	_ Storage = (*MemoryStorage)(nil)
+)
+
+// medianStoredValue returns the value a storage stores for a median vote,
+// the value of an abstention is 0.
+func medianStoredValue(value int, abstain bool) int {
+	if abstain {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,582 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+)
+
+// openSQLiteDB opens a fresh SQLite database in a temporary directory, call
+// the returned function to remove it.
+func openSQLiteDB(t *testing.T) (*sql.DB, func()) {
+	dir, err := ioutil.TempDir("", "sturavoting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenSQLite(path.Join(dir, "voting.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// newSQLiteStorage returns a storage with a fresh SQLite database in a
+// temporary directory, call the returned function to remove it.
+func newSQLiteStorage(t *testing.T) (*SQLStorage, func()) {
+	db, cleanup := openSQLiteDB(t)
+	storage := NewSQLStorage(db, SQLiteDialect, logrus.New())
+	if _, err := storage.Migrate(); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return storage, cleanup
+}
+
+// testStorages runs test with a MemoryStorage and a SQLStorage using SQLite.
+func testStorages(t *testing.T, test func(t *testing.T, storage Storage)) {
+	test(t, NewMemoryStorage())
+	sqliteStorage, cleanup := newSQLiteStorage(t)
+	defer cleanup()
+	test(t, sqliteStorage)
+}
+
+func TestStorageCollection(t *testing.T) {
+	testStorages(t, testCollection)
+}
+
+func testCollection(t *testing.T, storage Storage) {
+	categoryID, err := storage.InsertCategory("StuRa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisionID, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	voters := []*Voter{NewVoter("Alice", 2), NewVoter("Bob", 1)}
+	if err = storage.InsertVoters(revisionID, voters); err != nil {
+		t.Fatal(err)
+	}
+	median := &MedianVoting{Name: "Budget", MaxValue: 1000, PercentRequired: 0.5, Position: 7}
+	schulze := &SchulzeVoting{Name: "Chair", Options: []string{"A", "B", "No"}, StatusQuo: "No", PercentRequired: 0.5, Position: 3}
+	date := time.Date(2017, time.May, 9, 0, 0, 0, 0, time.UTC)
+	collection := &VotingCollection{Name: "Meeting", Date: date,
+		Groups: []*VotingGroup{&VotingGroup{Name: "Finances",
+			MedianVotings: []*MedianVoting{median}, SchulzeVotings: []*SchulzeVoting{schulze}}}}
+	if err = storage.InsertVotingCollection(revisionID, collection); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := storage.GetVotingCollection(collection.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Date.Equal(date) {
+		t.Errorf("Expected date %v, got %v", date, loaded.Date)
+	}
+	if len(loaded.Groups) != 1 || len(loaded.Groups[0].MedianVotings) != 1 || len(loaded.Groups[0].SchulzeVotings) != 1 {
+		t.Fatalf("Expected one group with one median and one schulze voting, got %s", loaded)
+	}
+	if options := loaded.Groups[0].SchulzeVotings[0].Options; len(options) != 3 || options[2] != "No" {
+		t.Errorf("Expected options [A B No], got %v", options)
+	}
+	if statusQuo := loaded.Groups[0].SchulzeVotings[0].StatusQuo; statusQuo != "No" {
+		t.Errorf("Expected status quo \"No\", got \"%s\"", statusQuo)
+	}
+	// the schulze voting comes first, positions are normalized
+	if votings := loaded.Groups[0].Votings(); votings[0].Name() != "Chair" || votings[0].Position() != 0 || votings[1].Position() != 1 {
+		t.Errorf("Expected Chair at position 0 and Budget at position 1, got %s", loaded.Groups[0])
+	}
+	alice, err := storage.GetVoterByName(revisionID, "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertMedianVote(median.ID, alice.ID, 500, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertSchulzeVote(schulze.ID, alice.ID, []int{1, 0, 2}); err != nil {
+		t.Fatal(err)
+	}
+	medianVotes, err := storage.GetMedianVotes(median.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(medianVotes) != 1 || medianVotes[0].Weight != 2 || medianVotes[0].Value != 500 {
+		t.Errorf("Expected one median vote with weight 2 and value 500, got %v", medianVotes)
+	}
+	if err = storage.UpdateMedianVote(median.ID, alice.ID, 500, true); err != nil {
+		t.Fatal(err)
+	}
+	if value, abstain, err := storage.GetMedianVote(median.ID, alice.ID); err != nil || value != 0 || !abstain {
+		t.Errorf("Expected an abstention with value 0, got %d, %v, %v", value, abstain, err)
+	}
+	// updating with the same values is not an error
+	if err = storage.UpdateMedianVote(median.ID, alice.ID, 500, true); err != nil {
+		t.Errorf("Expected no error when updating with the same values, got %v", err)
+	}
+	if err = storage.UpdateMedianVote(median.ID, alice.ID+100, 42, false); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows when updating a vote that doesn't exist, got %v", err)
+	}
+	ranking, err := storage.GetSchulzeVote(schulze.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compareSlices([][]int{ranking}, [][]int{[]int{1, 0, 2}}) {
+		t.Errorf("Expected ranking [1, 0, 2], got %v", ranking)
+	}
+	// a partial ranking and an abstention
+	if err = storage.UpdateSchulzeVote(schulze.ID, alice.ID, []int{Unranked, 1, Unranked}); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := storage.GetVoterByName(revisionID, "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertSchulzeVote(schulze.ID, bob.ID, []int{Unranked, Unranked, Unranked}); err != nil {
+		t.Fatal(err)
+	}
+	schulzeVotes, err := storage.GetSchulzeVotes(schulze.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schulzeVotes) != 2 {
+		t.Fatalf("Expected 2 schulze votes, got %d", len(schulzeVotes))
+	}
+	for _, vote := range schulzeVotes {
+		switch vote.Weight {
+		case 2:
+			if vote.Abstain || !compareSlices([][]int{vote.Ranking}, [][]int{[]int{Unranked, 1, Unranked}}) {
+				t.Errorf("Expected ranking [Unranked 1 Unranked] for Alice, got %+v", vote)
+			}
+		default:
+			if !vote.Abstain {
+				t.Errorf("Expected Bob to abstain, got %+v", vote)
+			}
+		}
+	}
+}
+
+func TestStorageListCollections(t *testing.T) {
+	testStorages(t, testListCollections)
+}
+
+// testListCollections inserts collections into two revisions and checks
+// that they're listed for their revision only and that all ids are set.
+func testListCollections(t *testing.T, storage Storage) {
+	categoryID, err := storage.InsertCategory("StuRa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCollection := func(name string, day int) *VotingCollection {
+		return &VotingCollection{Name: name, Date: time.Date(2017, time.May, day, 0, 0, 0, 0, time.UTC),
+			Groups: []*VotingGroup{&VotingGroup{Name: "TOP 1",
+				MedianVotings:  []*MedianVoting{&MedianVoting{Name: "Budget", MaxValue: 100, PercentRequired: 0.5}},
+				SchulzeVotings: []*SchulzeVoting{&SchulzeVoting{Name: "Chair", Options: []string{"A", "No"}, PercentRequired: 0.5, Position: 1}}}}}
+	}
+	later, earlier, other := newCollection("Later", 16), newCollection("Earlier", 9), newCollection("Other", 2)
+	for _, collection := range []*VotingCollection{later, earlier} {
+		if err = storage.InsertVotingCollection(first, collection); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = storage.InsertVotingCollection(second, other); err != nil {
+		t.Fatal(err)
+	}
+	group := later.Groups[0]
+	if later.ID == InvalidID || group.ID == InvalidID || group.CollectionID != later.ID ||
+		group.MedianVotings[0].ID == InvalidID || group.MedianVotings[0].GroupID != group.ID ||
+		group.SchulzeVotings[0].ID == InvalidID || group.SchulzeVotings[0].GroupID != group.ID ||
+		len(group.SchulzeVotings[0].OptionIDs) != 2 {
+		t.Errorf("Expected all ids to be set after inserting the collection, got %+v", later)
+	}
+	collections, err := storage.ListVotingCollections(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 2 || collections[0].ID != earlier.ID || collections[1].ID != later.ID {
+		t.Errorf("Expected the collections Earlier and Later, got %v", collections)
+	}
+	for _, collection := range collections {
+		if collection.VotersID != first {
+			t.Errorf("Expected collection %s to belong to revision %d, got %d", collection.Name, first, collection.VotersID)
+		}
+	}
+	if _, err = storage.GetVotingCollection(other.ID + 100); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for a collection that doesn't exist, got %v", err)
+	}
+}
+
+func TestStorageAdmins(t *testing.T) {
+	testStorages(t, testAdmins)
+}
+
+func testAdmins(t *testing.T, storage Storage) {
+	// setting the role twice must not fail
+	for i := 0; i < 2; i++ {
+		if err := storage.SetAdmin(42, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if isAdmin, err := storage.IsAdmin(42); err != nil || !isAdmin {
+		t.Errorf("Expected user 42 to be an admin, got %v (error %v)", isAdmin, err)
+	}
+	if err := storage.SetAdmin(42, false); err != nil {
+		t.Fatal(err)
+	}
+	if isAdmin, err := storage.IsAdmin(42); err != nil || isAdmin {
+		t.Errorf("Expected user 42 not to be an admin, got %v (error %v)", isAdmin, err)
+	}
+}
+
+func TestStorageUserVoters(t *testing.T) {
+	testStorages(t, testUserVoters)
+}
+
+func testUserVoters(t *testing.T, storage Storage) {
+	categoryID, err := storage.InsertCategory("StuRa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertVoters(first, []*Voter{NewVoter("Alice", 2), NewVoter("Bob", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := storage.GetVoterByName(first, "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.LinkUserToVoter(42, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	// the link must be carried forward to the new revision
+	second, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertVoters(second, []*Voter{NewVoter("Alice", 3)}); err != nil {
+		t.Fatal(err)
+	}
+	voter, err := storage.GetUserVoter(42, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if voter.Name != "Alice" || voter.Weight != 3 {
+		t.Errorf("Expected user 42 to be linked to Alice with weight 3, got %s with weight %d", voter.Name, voter.Weight)
+	}
+	if err = storage.UnlinkUser(42, second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = storage.GetUserVoter(42, second); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows after unlinking the user, got %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	storage, cleanup := newSQLiteStorage(t)
+	defer cleanup()
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestSchemaVersion {
+		t.Errorf("Expected schema version %d after migrating, got %d", LatestSchemaVersion, version)
+	}
+	applied, err := storage.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations to be applied twice, got %v", applied)
+	}
+	if _, err = storage.DB.Exec("INSERT INTO schema_version (version, applied) VALUES (?, ?);", LatestSchemaVersion+1, Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = storage.CheckSchema(); err == nil {
+		t.Error("Expected an error for a newer schema version")
+	}
+}
+
+// TestMigrateBaseline migrates a database created before schema versions
+// were introduced, i.e. with the initial tables and without a
+// schema_version table.
+func TestMigrateBaseline(t *testing.T) {
+	db, cleanup := openSQLiteDB(t)
+	defer cleanup()
+	for _, query := range sqliteSchema {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	baseline := []string{
+		"INSERT INTO categories (id, name, created) VALUES (1, 'StuRa', '2017-01-01 00:00:00');",
+		"INSERT INTO voters_revisions (id, category_id, created) VALUES (1, 1, '2017-01-01 00:00:00');",
+		"INSERT INTO voters (id, revision_id, name, weight) VALUES (1, 1, 'Alice', 2), (2, 1, 'Bob', 1);",
+		"INSERT INTO voting_collections (id, voters_id, name, voting_day) VALUES (1, 1, 'Meeting', '2017-01-02 00:00:00');",
+		"INSERT INTO voting_groups (id, collection_id, name) VALUES (1, 1, 'Finances');",
+		"INSERT INTO median_votings (id, group_id, name, max_value, percent_required) VALUES (1, 1, 'Budget', 1000, 0.5);",
+		"INSERT INTO schulze_votings (id, group_id, name, percent_required) VALUES (1, 1, 'Motion', 0.5);",
+		"INSERT INTO schulze_options (id, voting_id, `option`) VALUES (1, 1, 'Yes'), (2, 1, 'No');",
+		"INSERT INTO median_votes (voting_id, voter_id, value) VALUES (1, 1, 500), (1, 2, 300);",
+		"INSERT INTO schulze_votes (option_id, voter_id, sorting_position) VALUES (1, 1, 0), (2, 1, 1);",
+	}
+	for _, query := range baseline {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage := NewSQLStorage(db, SQLiteDialect, logrus.New())
+	applied, err := storage.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != LatestSchemaVersion {
+		t.Errorf("Expected all %d migrations to be applied, got %v", LatestSchemaVersion, applied)
+	}
+
+	collection, err := storage.GetVotingCollection(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collection.Groups) != 1 || len(collection.Groups[0].MedianVotings) != 1 || len(collection.Groups[0].SchulzeVotings) != 1 {
+		t.Fatalf("Expected one group with one median and one schulze voting, got %v", collection.Groups)
+	}
+	median := collection.Groups[0].MedianVotings[0]
+	if median.Name != "Budget" || median.MaxValue != 1000 || median.Position != 0 {
+		t.Errorf("Unexpected median voting after migrating: %v", median)
+	}
+	schulze := collection.Groups[0].SchulzeVotings[0]
+	if schulze.Name != "Motion" || schulze.Position != 0 || schulze.StatusQuo != "" {
+		t.Errorf("Unexpected schulze voting after migrating: %v", schulze)
+	}
+	if len(schulze.Options) != 2 || schulze.Options[0] != "Yes" || schulze.Options[1] != "No" {
+		t.Errorf("Expected options [Yes No] after migrating, got %v", schulze.Options)
+	}
+
+	value, abstain, err := storage.GetMedianVote(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != 500 || abstain {
+		t.Errorf("Expected vote for 500 without abstention after migrating, got %d (abstain %v)", value, abstain)
+	}
+	medianVotes, err := storage.GetMedianVotes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(medianVotes) != 2 {
+		t.Fatalf("Expected two median votes after migrating, got %d", len(medianVotes))
+	}
+	for _, vote := range medianVotes {
+		if vote.Abstain {
+			t.Errorf("Expected no abstentions after migrating, got %v", vote)
+		}
+	}
+	ranking, err := storage.GetSchulzeVote(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranking) != 2 || ranking[0] != 0 || ranking[1] != 1 {
+		t.Errorf("Expected ranking [0 1] after migrating, got %v", ranking)
+	}
+
+	// the rebuilt voting tables are still referenced by the votes
+	if err = storage.InsertMedianVote(42, 1, 100, false); err == nil {
+		t.Error("Expected an error when voting in a voting that doesn't exist")
+	}
+	if _, err = storage.DB.Exec("DELETE FROM median_votings WHERE id = 1;"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = storage.GetMedianVote(1, 1); err != sql.ErrNoRows {
+		t.Errorf("Expected votes to be deleted with their voting, got %v", err)
+	}
+
+	// the migrated tables must accept new rows using the new columns
+	if err = storage.InsertSchulzeVote(1, 2, []int{Unranked, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if ranking, err = storage.GetSchulzeVote(1, 2); err != nil || ranking[0] != Unranked || ranking[1] != 1 {
+		t.Errorf("Expected ranking [%d 1] for the new vote, got %v (error %v)", Unranked, ranking, err)
+	}
+}
+
+func TestStorageImportExample(t *testing.T) {
+	testStorages(t, testImportExample)
+}
+
+// testImportExample imports the example agenda, it contains several
+// votings with the same name in a group.
+func testImportExample(t *testing.T, storage Storage) {
+	f, err := os.Open(path.Join("examples", "stura-9.5.17.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	collection, err := ParseVotingCollection(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	categoryID, err := storage.InsertCategory("StuRa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisionID, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertVotingCollection(revisionID, collection); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := storage.GetVotingCollection(collection.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	votings := loaded.Groups[0].Votings()
+	if len(votings) != 5 {
+		t.Fatalf("Expected 5 votings in the first group, got %d", len(votings))
+	}
+	for i, voting := range votings[2:] {
+		if voting.Name() != "Abstimmungen" || voting.Position() != i+2 {
+			t.Errorf("Expected \"Abstimmungen\" at position %d, got \"%s\" at %d", i+2, voting.Name(), voting.Position())
+		}
+	}
+	if options := votings[3].Schulze.Options; len(options) != 2 || options[0] != "Quotierung Redeliste (Vorstand)" {
+		t.Errorf("Expected the options of the second \"Abstimmungen\", got %v", options)
+	}
+}
+
+func TestStorageCloneRevision(t *testing.T) {
+	testStorages(t, testCloneRevision)
+}
+
+func testCloneRevision(t *testing.T, storage Storage) {
+	categoryID, err := storage.InsertCategory("StuRa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisionID, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertVoters(revisionID, []*Voter{NewVoter("Alice", 2), NewVoter("Bob", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	diff := NewVotersDiff()
+	diff.Remove = append(diff.Remove, "Bob")
+	diff.Reweight["Alice"] = 5
+	cloneID, err := storage.CloneVotersRevision(revisionID, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	voters, err := storage.ListVoters(cloneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voters) != 1 || voters[0].Name != "Alice" || voters[0].Weight != 5 {
+		t.Errorf("Expected only Alice with weight 5 in the cloned revision, got %v", voters)
+	}
+	// a diff that doesn't match must not create a revision
+	diff = NewVotersDiff()
+	diff.Remove = append(diff.Remove, "Dave")
+	if _, err = storage.CloneVotersRevision(revisionID, diff); err == nil {
+		t.Error("Expected error when removing a voter that doesn't exist")
+	}
+	revisions, err := storage.ListVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Errorf("Expected 2 revisions, got %d", len(revisions))
+	}
+}
+
+func TestStorageCloneOldRevision(t *testing.T) {
+	testStorages(t, testCloneOldRevision)
+}
+
+// testCloneOldRevision clones a revision that is not the latest revision,
+// the links of the cloned revision must be carried forward.
+func testCloneOldRevision(t *testing.T, storage Storage) {
+	categoryID, err := storage.InsertCategory("StuRa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertVoters(first, []*Voter{NewVoter("Alice", 2), NewVoter("Bob", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := storage.GetVoterByName(first, "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.LinkUserToVoter(42, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	second, err := storage.CloneVotersRevision(first, NewVotersDiff())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := storage.GetVoterByName(second, "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.LinkUserToVoter(42, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	third, err := storage.CloneVotersRevision(first, NewVotersDiff())
+	if err != nil {
+		t.Fatal(err)
+	}
+	voter, err := storage.GetUserVoter(42, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if voter.Name != "Alice" {
+		t.Errorf("Expected the link to Alice from the cloned revision, got %s", voter.Name)
+	}
+}
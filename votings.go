@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -48,10 +49,10 @@ type VotersRevision struct {
 }
 
 type Voter struct {
-	Name       string
-	Weight     int
-	ID         uint
-	RevisionID uint
+	Name       string `json:"name" yaml:"name"`
+	Weight     int    `json:"weight" yaml:"weight"`
+	ID         uint   `json:"id,omitempty" yaml:"id,omitempty"`
+	RevisionID uint   `json:"revision_id,omitempty" yaml:"revision_id,omitempty"`
 }
 
 func NewVoter(name string, weight int) *Voter {
@@ -73,6 +74,8 @@ func (err *SyntaxError) Error() string {
 
 func ParseVoters(r io.Reader) ([]*Voter, error) {
 	res := make([]*Voter, 0)
+	// maps the names to the line they appeared in
+	names := make(map[string]int)
 	scanner := bufio.NewScanner(r)
 	lineNum := 1
 	for scanner.Scan() {
@@ -85,33 +88,232 @@ func ParseVoters(r io.Reader) ([]*Voter, error) {
 		if !strings.HasPrefix(line, "*") {
 			return nil, NewSyntaxError(lineNum, "Line must start with a *")
 		}
-		line = line[1:]
-		// line must end with : some int
-		lastColon := strings.LastIndex(line, ":")
-		if lastColon < 0 {
-			return nil, NewSyntaxError(lineNum, "Line must contain \": weight\"")
+		voter, err := parseVoterLine(line[1:], lineNum)
+		if err != nil {
+			return nil, err
 		}
-		name, weightStr := strings.TrimSpace(line[:lastColon]), strings.TrimSpace(line[lastColon+1:])
-		if utf8.RuneCountInString(name) > 150 {
-			return nil, NewSyntaxError(lineNum, "Name must be at most 150 charachters long")
+		if first, has := names[voter.Name]; has {
+			return nil, NewSyntaxError(lineNum, fmt.Sprintf("Voter \"%s\" already given in line %d", voter.Name, first))
 		}
-		if name == "" {
-			return nil, NewSyntaxError(lineNum, "Name is not allowed to be empty")
+		names[voter.Name] = lineNum
+		res = append(res, voter)
+		lineNum++
+	}
+	return res, nil
+}
+
+// parseVoterLine parses "name: weight".
+func parseVoterLine(line string, lineNum int) (*Voter, error) {
+	// line must end with : some int
+	lastColon := strings.LastIndex(line, ":")
+	if lastColon < 0 {
+		return nil, NewSyntaxError(lineNum, "Line must contain \": weight\"")
+	}
+	name, err := parseVoterName(line[:lastColon], lineNum)
+	if err != nil {
+		return nil, err
+	}
+	weight, parseErr := strconv.Atoi(strings.TrimSpace(line[lastColon+1:]))
+	if parseErr != nil {
+		return nil, NewSyntaxError(lineNum, parseErr.Error())
+	}
+	return NewVoter(name, weight), nil
+}
+
+func parseVoterName(name string, lineNum int) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > 150 {
+		return "", NewSyntaxError(lineNum, "Name must be at most 150 charachters long")
+	}
+	if name == "" {
+		return "", NewSyntaxError(lineNum, "Name is not allowed to be empty")
+	}
+	return name, nil
+}
+
+// VotersDiff describes the changes between the voters of two revisions.
+type VotersDiff struct {
+	// Add contains the voters that are added.
+	Add []*Voter
+	// Remove contains the names of the voters that are removed.
+	Remove []string
+	// Reweight maps the names of voters to their new weight.
+	Reweight map[string]int
+}
+
+// DiffError is returned by VotersDiff.Apply if the diff doesn't match the
+// voters.
+type DiffError struct {
+	message string
+}
+
+func (err *DiffError) Error() string {
+	return err.message
+}
+
+// NewVotersDiff returns an empty diff.
+func NewVotersDiff() *VotersDiff {
+	return &VotersDiff{Add: make([]*Voter, 0), Remove: make([]string, 0),
+		Reweight: make(map[string]int)}
+}
+
+// Apply applies the diff to voters and returns the new voters, voters is not
+// changed. The voters in the result don't have an ID or RevisionID.
+// It returns a *DiffError if a voter that should be added exists already or
+// if a voter that should be removed or reweighted doesn't exist.
+func (diff *VotersDiff) Apply(voters []*Voter) ([]*Voter, error) {
+	byName := make(map[string]*Voter, len(voters))
+	for _, voter := range voters {
+		byName[voter.Name] = NewVoter(voter.Name, voter.Weight)
+	}
+	for _, name := range diff.Remove {
+		if _, has := byName[name]; !has {
+			return nil, &DiffError{fmt.Sprintf("Can't remove voter \"%s\": No such voter", name)}
 		}
-		weight, parseErr := strconv.Atoi(weightStr)
-		if parseErr != nil {
-			return nil, NewSyntaxError(lineNum, parseErr.Error())
+		delete(byName, name)
+	}
+	for name, weight := range diff.Reweight {
+		voter, has := byName[name]
+		if !has {
+			return nil, &DiffError{fmt.Sprintf("Can't change weight of voter \"%s\": No such voter", name)}
+		}
+		voter.Weight = weight
+	}
+	res := make([]*Voter, 0, len(voters)+len(diff.Add))
+	// keep the order of voters
+	for _, voter := range voters {
+		if newVoter, has := byName[voter.Name]; has {
+			res = append(res, newVoter)
+		}
+	}
+	for _, voter := range diff.Add {
+		if _, has := byName[voter.Name]; has {
+			return nil, &DiffError{fmt.Sprintf("Can't add voter \"%s\": Voter exists already", voter.Name)}
+		}
+		newVoter := NewVoter(voter.Name, voter.Weight)
+		byName[voter.Name] = newVoter
+		res = append(res, newVoter)
+	}
+	return res, nil
+}
+
+// WeightChange describes the change of a voter's weight.
+type WeightChange struct {
+	Name      string `json:"name" yaml:"name"`
+	OldWeight int    `json:"old_weight" yaml:"old_weight"`
+	NewWeight int    `json:"new_weight" yaml:"new_weight"`
+}
+
+// VotersComparison is the result of comparing two lists of voters, see
+// CompareVoters. All lists are sorted by name.
+type VotersComparison struct {
+	Added     []*Voter        `json:"added" yaml:"added"`
+	Removed   []*Voter        `json:"removed" yaml:"removed"`
+	Changed   []*WeightChange `json:"changed" yaml:"changed"`
+	OldWeight int             `json:"old_weight" yaml:"old_weight"`
+	NewWeight int             `json:"new_weight" yaml:"new_weight"`
+}
+
+// CompareVoters compares the voters in oldVoters and newVoters, voters are
+// identified by their names.
+func CompareVoters(oldVoters, newVoters []*Voter) *VotersComparison {
+	res := &VotersComparison{Added: make([]*Voter, 0), Removed: make([]*Voter, 0),
+		Changed: make([]*WeightChange, 0)}
+	oldByName := make(map[string]*Voter, len(oldVoters))
+	for _, voter := range oldVoters {
+		oldByName[voter.Name] = voter
+		res.OldWeight += voter.Weight
+	}
+	newByName := make(map[string]*Voter, len(newVoters))
+	for _, voter := range newVoters {
+		newByName[voter.Name] = voter
+		res.NewWeight += voter.Weight
+		oldVoter, has := oldByName[voter.Name]
+		switch {
+		case !has:
+			res.Added = append(res.Added, voter)
+		case oldVoter.Weight != voter.Weight:
+			res.Changed = append(res.Changed, &WeightChange{Name: voter.Name,
+				OldWeight: oldVoter.Weight, NewWeight: voter.Weight})
+		}
+	}
+	for _, voter := range oldVoters {
+		if _, has := newByName[voter.Name]; !has {
+			res.Removed = append(res.Removed, voter)
+		}
+	}
+	sort.Slice(res.Added, func(i, j int) bool { return res.Added[i].Name < res.Added[j].Name })
+	sort.Slice(res.Removed, func(i, j int) bool { return res.Removed[i].Name < res.Removed[j].Name })
+	sort.Slice(res.Changed, func(i, j int) bool { return res.Changed[i].Name < res.Changed[j].Name })
+	return res
+}
+
+// CompareRevisions compares the voters of the revisions with ids oldID and
+// newID, the revisions may belong to different categories.
+func CompareRevisions(storage Storage, oldID, newID uint) (*VotersComparison, error) {
+	oldVoters, err := storage.ListVoters(oldID)
+	if err != nil {
+		return nil, err
+	}
+	newVoters, err := storage.ListVoters(newID)
+	if err != nil {
+		return nil, err
+	}
+	return CompareVoters(oldVoters, newVoters), nil
+}
+
+// ParseVotersDiff parses a diff. Each line either adds a voter
+// ("+ name: weight"), removes a voter ("- name") or changes the weight of a
+// voter ("~ name: weight").
+func ParseVotersDiff(r io.Reader) (*VotersDiff, error) {
+	res := NewVotersDiff()
+	scanner := bufio.NewScanner(r)
+	lineNum := 1
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			lineNum++
+			continue
+		}
+		switch line[0] {
+		case '+':
+			voter, err := parseVoterLine(line[1:], lineNum)
+			if err != nil {
+				return nil, err
+			}
+			res.Add = append(res.Add, voter)
+		case '-':
+			name, err := parseVoterName(line[1:], lineNum)
+			if err != nil {
+				return nil, err
+			}
+			res.Remove = append(res.Remove, name)
+		case '~':
+			voter, err := parseVoterLine(line[1:], lineNum)
+			if err != nil {
+				return nil, err
+			}
+			if _, has := res.Reweight[voter.Name]; has {
+				return nil, NewSyntaxError(lineNum, fmt.Sprintf("Weight of \"%s\" changed twice", voter.Name))
+			}
+			res.Reweight[voter.Name] = voter.Weight
+		default:
+			return nil, NewSyntaxError(lineNum, "Line must start with +, - or ~")
 		}
-		res = append(res, NewVoter(name, weight))
 		lineNum++
 	}
 	return res, nil
 }
 
 type MedianVoting struct {
-	Name            string
-	MaxValue        int
-	PercentRequired float64
+	Name            string  `json:"name" yaml:"name"`
+	MaxValue        int     `json:"max_value" yaml:"max_value"`
+	PercentRequired float64 `json:"percent_required" yaml:"percent_required"`
+	// Position is the position of the voting in its group, the positions of
+	// median and schulze votings share the same range.
+	Position int  `json:"position" yaml:"position"`
+	ID       uint `json:"id,omitempty" yaml:"id,omitempty"`
+	GroupID  uint `json:"group_id,omitempty" yaml:"group_id,omitempty"`
 }
 
 func (voting *MedianVoting) String() string {
@@ -120,9 +322,34 @@ func (voting *MedianVoting) String() string {
 }
 
 type SchulzeVoting struct {
-	Name            string
-	Options         []string
-	PercentRequired float64
+	Name            string   `json:"name" yaml:"name"`
+	Options         []string `json:"options" yaml:"options"`
+	PercentRequired float64  `json:"percent_required" yaml:"percent_required"`
+	// OptionIDs contains the database ids of the options, OptionIDs[i] is the
+	// id of Options[i]. It is nil if the voting was not stored / retrieved
+	// from the database.
+	OptionIDs []uint `json:"option_ids,omitempty" yaml:"option_ids,omitempty"`
+	// StatusQuo is the option the other options are compared to (usually
+	// "No"), if it is empty the last option is the status quo option.
+	StatusQuo string `json:"status_quo,omitempty" yaml:"status_quo,omitempty"`
+	// Position is the position of the voting in its group, see MedianVoting.
+	Position int  `json:"position" yaml:"position"`
+	ID       uint `json:"id,omitempty" yaml:"id,omitempty"`
+	GroupID  uint `json:"group_id,omitempty" yaml:"group_id,omitempty"`
+}
+
+// StatusQuoIndex returns the index of the status quo option, see StatusQuo.
+// It returns -1 if StatusQuo is not an option of the voting.
+func (voting *SchulzeVoting) StatusQuoIndex() int {
+	if voting.StatusQuo == "" {
+		return len(voting.Options) - 1
+	}
+	for i, option := range voting.Options {
+		if option == voting.StatusQuo {
+			return i
+		}
+	}
+	return -1
 }
 
 func (voting *SchulzeVoting) String() string {
@@ -136,40 +363,85 @@ func (voting *SchulzeVoting) String() string {
 }
 
 type VotingGroup struct {
-	Name           string
-	MedianVotings  []*MedianVoting
-	SchulzeVotings []*SchulzeVoting
+	Name           string           `json:"name" yaml:"name"`
+	MedianVotings  []*MedianVoting  `json:"median_votings" yaml:"median_votings"`
+	SchulzeVotings []*SchulzeVoting `json:"schulze_votings" yaml:"schulze_votings"`
+	ID             uint             `json:"id,omitempty" yaml:"id,omitempty"`
+	CollectionID   uint             `json:"collection_id,omitempty" yaml:"collection_id,omitempty"`
 }
 
 func (group *VotingGroup) String() string {
-	var wg sync.WaitGroup
-	wg.Add(2)
-	medianStrings := make([]string, len(group.MedianVotings))
-	schulzeStrings := make([]string, len(group.SchulzeVotings))
-	var medianRepr, schulzeRepr string
-	go func() {
-		defer wg.Done()
-		for i, voting := range group.MedianVotings {
-			medianStrings[i] = fmt.Sprintf("  %s", voting.String())
+	votings := group.Votings()
+	votingStrings := make([]string, len(votings))
+	for i, voting := range votings {
+		if voting.Median != nil {
+			votingStrings[i] = fmt.Sprintf("  %s", voting.Median.String())
+		} else {
+			votingStrings[i] = fmt.Sprintf("  %s", voting.Schulze.String())
 		}
-		medianRepr = strings.Join(medianStrings, "\n")
-	}()
+	}
+	return fmt.Sprintf("VotingGroup: \"%s\"\n%s", group.Name, strings.Join(votingStrings, "\n"))
+}
+
+// GroupVoting is an entry in the ordered list of votings of a group, exactly
+// one of Median and Schulze is not nil.
+type GroupVoting struct {
+	Median  *MedianVoting
+	Schulze *SchulzeVoting
+}
 
-	go func() {
-		defer wg.Done()
-		for i, voting := range group.SchulzeVotings {
-			schulzeStrings[i] = fmt.Sprintf("  %s", voting.String())
+// Name returns the name of the voting.
+func (voting *GroupVoting) Name() string {
+	if voting.Median != nil {
+		return voting.Median.Name
+	}
+	return voting.Schulze.Name
+}
+
+// Position returns the position of the voting.
+func (voting *GroupVoting) Position() int {
+	if voting.Median != nil {
+		return voting.Median.Position
+	}
+	return voting.Schulze.Position
+}
+
+// Votings returns the median and schulze votings of the group ordered by
+// their position, i.e. in the order they were put to the vote.
+// Votings with the same position are ordered median votings first, each
+// type in the order of its slice.
+func (group *VotingGroup) Votings() []*GroupVoting {
+	res := make([]*GroupVoting, 0, len(group.MedianVotings)+len(group.SchulzeVotings))
+	for _, voting := range group.MedianVotings {
+		res = append(res, &GroupVoting{Median: voting})
+	}
+	for _, voting := range group.SchulzeVotings {
+		res = append(res, &GroupVoting{Schulze: voting})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Position() < res[j].Position()
+	})
+	return res
+}
+
+// NormalizePositions sets the positions of all votings in the group to
+// 0, 1, ... without changing their order as returned by Votings.
+func (group *VotingGroup) NormalizePositions() {
+	for i, voting := range group.Votings() {
+		if voting.Median != nil {
+			voting.Median.Position = i
+		} else {
+			voting.Schulze.Position = i
 		}
-		schulzeRepr = strings.Join(schulzeStrings, "\n")
-	}()
-	wg.Wait()
-	return fmt.Sprintf("VotingGroup: \"%s\"\n%s\n%s", group.Name, medianRepr, schulzeRepr)
+	}
 }
 
 type VotingCollection struct {
-	Name   string
-	Date   time.Time
-	Groups []*VotingGroup
+	Name     string         `json:"name" yaml:"name"`
+	Date     time.Time      `json:"date" yaml:"date"`
+	Groups   []*VotingGroup `json:"groups" yaml:"groups"`
+	ID       uint           `json:"id,omitempty" yaml:"id,omitempty"`
+	VotersID uint           `json:"voters_id,omitempty" yaml:"voters_id,omitempty"`
 }
 
 func (collection *VotingCollection) String() string {
@@ -197,15 +469,21 @@ const (
 	// cTopLevelState is the state when parsing in the top level, in this state
 	// we expect a voting group in the form
 	// ## GROUP-NAME
+	// or the default majority for all votings in the collection in the form
+	// majority: PERCENT (see ParsePercentRequired)
 	cTopLevelState
 	// cGroupState is the state when parsing items inside a group.
 	// We expect a voting in the form
 	// ### VOTING-NAME
+	// or the default majority for all votings in the group
 	cGroupState
 	// cVotingState is the state when parsing options for a voting.
 	// We expect either
-	// 1. * VOTING-OPTION to start a Schulze voting
+	// 1. * VOTING-OPTION to start a Schulze voting, the status quo option
+	// the other options are compared to is marked with *! VOTING-OPTION
+	// (by default it's the last option)
 	// 2. - NUMBER to start a media voting
+	// 3. the majority required for this voting
 	cVotingState
 	// cGroupOrVoting is the state when parsing either a new voting group
 	// or a new voting is expected
@@ -249,18 +527,54 @@ func parseConcurrency(str string) (int, error) {
 	return strconv.Atoi(firstPart + secondPart)
 }
 
+// FormatConcurrency is the inverse of parseConcurrency, it formats a value
+// in cents as XXXX.XX.
+func FormatConcurrency(value int) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/100, value%100)
+}
+
 func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 	scanner := bufio.NewScanner(r)
 	lineNumber := 1
 	state := cStartState
 	res := &VotingCollection{Name: "", Groups: make([]*VotingGroup, 0)}
 	lastVotingName := ""
+	// the majorities set for the collection, the last group and the next
+	// voting, -1.0 if not set
+	collectionPercent, groupPercent, votingPercent := -1.0, -1.0, -1.0
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			lineNumber++
 			continue
 		}
+		line, isStatusQuo := splitStatusQuo(line)
+		if percent, isMajority, err := handleMajorityLine(line, lineNumber); err != nil {
+			return nil, err
+		} else if isMajority {
+			var target *float64
+			switch state {
+			case cTopLevelState:
+				target = &collectionPercent
+			case cGroupState:
+				target = &groupPercent
+			case cVotingState:
+				target = &votingPercent
+			default:
+				return nil, NewSyntaxError(lineNumber, "A majority is only allowed directly after the title, a group or a voting")
+			}
+			if *target >= 0.0 {
+				return nil, NewSyntaxError(lineNumber, "Majority is set more than once")
+			}
+			*target = percent
+			lineNumber++
+			continue
+		}
 		switch state {
 		default:
 			return nil, errors.New("Invalid state while parsing voting collection")
@@ -281,6 +595,7 @@ func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 					MedianVotings:  make([]*MedianVoting, 0),
 					SchulzeVotings: make([]*SchulzeVoting, 0)}
 				res.Groups = append(res.Groups, group)
+				groupPercent = -1.0
 				state = cGroupState
 			}
 		case cGroupState:
@@ -299,12 +614,17 @@ func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 					return nil, NewSyntaxError(lineNumber, "Got voting option without a valid group or voting name")
 				}
 				lastGroup := res.Groups[len(res.Groups)-1]
+				percentRequired := firstPercentSet(votingPercent, groupPercent, collectionPercent)
 				switch vType {
 				default:
 					return nil, errors.New("Invalid voting type")
 				case schulzeVoting:
 					// add a new schulze voting with the last name and the new option
-					newVoting := &SchulzeVoting{Name: lastVotingName, Options: []string{str}, PercentRequired: -1.0}
+					newVoting := &SchulzeVoting{Name: lastVotingName, Options: []string{str},
+						PercentRequired: percentRequired, Position: numVotings(lastGroup)}
+					if isStatusQuo {
+						newVoting.StatusQuo = str
+					}
 					lastGroup.SchulzeVotings = append(lastGroup.SchulzeVotings, newVoting)
 					state = cSchulzeOptionsState
 				case medianVoting:
@@ -313,11 +633,13 @@ func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 					if err != nil {
 						return nil, NewSyntaxError(lineNumber, err.Error())
 					}
-					newVoting := &MedianVoting{Name: lastVotingName, MaxValue: value, PercentRequired: -1.0}
+					newVoting := &MedianVoting{Name: lastVotingName, MaxValue: value,
+						PercentRequired: percentRequired, Position: numVotings(lastGroup)}
 					lastGroup.MedianVotings = append(lastGroup.MedianVotings, newVoting)
 					state = cGroupOrVoting
 				}
 				lastVotingName = ""
+				votingPercent = -1.0
 			}
 		case cSchulzeOptionsState:
 			// expect either a schulze option, a voting or a group
@@ -341,7 +663,18 @@ func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 					}
 					// everything ok, append new option to last voting
 					lastVoting := lastGroup.SchulzeVotings[len(lastGroup.SchulzeVotings)-1]
+					for _, option := range lastVoting.Options {
+						if option == name {
+							return nil, NewSyntaxError(lineNumber, fmt.Sprintf("Option \"%s\" exists more than once", name))
+						}
+					}
 					lastVoting.Options = append(lastVoting.Options, name)
+					if isStatusQuo {
+						if lastVoting.StatusQuo != "" {
+							return nil, NewSyntaxError(lineNumber, "Status quo option is set more than once")
+						}
+						lastVoting.StatusQuo = name
+					}
 					// state stays the same
 				case optionStateVoting:
 					lastVotingName = name
@@ -351,6 +684,7 @@ func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 						MedianVotings:  make([]*MedianVoting, 0),
 						SchulzeVotings: make([]*SchulzeVoting, 0)}
 					res.Groups = append(res.Groups, group)
+					groupPercent = -1.0
 					state = cGroupState
 				}
 			}
@@ -369,6 +703,7 @@ func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 						MedianVotings:  make([]*MedianVoting, 0),
 						SchulzeVotings: make([]*SchulzeVoting, 0)}
 					res.Groups = append(res.Groups, group)
+					groupPercent = -1.0
 					state = cGroupState
 				}
 			}
@@ -378,6 +713,111 @@ func ParseVotingCollection(r io.Reader) (*VotingCollection, error) {
 	return res, nil
 }
 
+// splitStatusQuo replaces the status quo marker "*!" at the beginning of
+// line by "*", the second return value is true if the line is marked.
+func splitStatusQuo(line string) (string, bool) {
+	if strings.HasPrefix(line, "*! ") {
+		return "*" + line[2:], true
+	}
+	return line, false
+}
+
+// firstPercentSet returns the first percentage that is not negative, or -1.0
+// if none is set.
+func firstPercentSet(percents ...float64) float64 {
+	for _, percent := range percents {
+		if percent >= 0.0 {
+			return percent
+		}
+	}
+	return -1.0
+}
+
+// ParsePercentRequired parses the majority required for a voting. It
+// accepts a fraction "2/3", a percentage "50%" or a decimal number "0.5"
+// (or "0,5"), the result must be a valid Majority.
+func ParsePercentRequired(str string) (float64, error) {
+	res, err := parseFraction(str)
+	if err != nil {
+		return -1.0, err
+	}
+	if err := Majority(res).Validate(); err != nil {
+		return -1.0, fmt.Errorf("Invalid majority \"%s\": Must be at least 0 and less than 1", strings.TrimSpace(str))
+	}
+	return res, nil
+}
+
+// ParseQuorum parses a quorum in the same formats as ParsePercentRequired,
+// the result must be between 0 and 1.
+func ParseQuorum(str string) (float64, error) {
+	res, err := parseFraction(str)
+	if err != nil {
+		return -1.0, err
+	}
+	// also rejects NaN
+	if !(res >= 0.0 && res <= 1.0) {
+		return -1.0, fmt.Errorf("Invalid quorum \"%s\": Must be between 0 and 1", strings.TrimSpace(str))
+	}
+	return res, nil
+}
+
+// parseFraction parses a fraction, percentage or decimal number as
+// described in ParsePercentRequired without checking its range.
+func parseFraction(str string) (float64, error) {
+	str = strings.TrimSpace(str)
+	var res float64
+	var err error
+	switch {
+	case strings.Contains(str, "/"):
+		parts := strings.SplitN(str, "/", 2)
+		var numerator, denominator int
+		if numerator, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+			return -1.0, fmt.Errorf("Invalid majority \"%s\": Numerator must be a number", str)
+		}
+		if denominator, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil || denominator <= 0 {
+			return -1.0, fmt.Errorf("Invalid majority \"%s\": Denominator must be a positive number", str)
+		}
+		res = float64(numerator) / float64(denominator)
+	case strings.HasSuffix(str, "%"):
+		res, err = strconv.ParseFloat(strings.Replace(strings.TrimSpace(str[:len(str)-1]), ",", ".", 1), 64)
+		if err != nil {
+			return -1.0, fmt.Errorf("Invalid majority \"%s\": Not a valid percentage", str)
+		}
+		res /= 100.0
+	default:
+		res, err = strconv.ParseFloat(strings.Replace(str, ",", ".", 1), 64)
+		if err != nil {
+			return -1.0, fmt.Errorf("Invalid majority \"%s\": Expected a fraction, percentage or number", str)
+		}
+	}
+	return res, nil
+}
+
+// FormatPercentRequired formats the majority as a number that is parsed back
+// to the same value by ParsePercentRequired.
+func FormatPercentRequired(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64)
+}
+
+// handleMajorityLine parses a line of the form "majority: PERCENT", the
+// second return value is false if the line is not of this form.
+func handleMajorityLine(line string, lineNumber int) (float64, bool, error) {
+	if !strings.HasPrefix(line, "majority:") {
+		return -1.0, false, nil
+	}
+	percent, err := ParsePercentRequired(line[len("majority:"):])
+	if err != nil {
+		return -1.0, true, NewSyntaxError(lineNumber, err.Error())
+	}
+	return percent, true, nil
+}
+
+// numVotings returns the number of votings in the group, used as the
+// position of the next voting while parsing.
+func numVotings(group *VotingGroup) int {
+	return len(group.MedianVotings) + len(group.SchulzeVotings)
+}
+
 func validateVotingsString(s string) error {
 	if s == "" || utf8.RuneCountInString(s) > 150 {
 		return errors.New("Name must be not empty and at most 150 characters long")
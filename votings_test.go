@@ -0,0 +1,245 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVotersDuplicate(t *testing.T) {
+	_, err := ParseVoters(strings.NewReader("* Alice: 1\n* Bob: 2\n\n* Alice: 3\n"))
+	if err == nil {
+		t.Fatal("Expected error for a voter given twice")
+	}
+	if syntaxErr, ok := err.(*SyntaxError); !ok || syntaxErr.lineNumber != 4 {
+		t.Errorf("Expected a syntax error in line 4, got %v", err)
+	}
+}
+
+func TestVotersDiff(t *testing.T) {
+	diff, err := ParseVotersDiff(strings.NewReader("+ Carol: 4\n\n- Bob\n~ Alice: 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	voters := []*Voter{NewVoter("Alice", 2), NewVoter("Bob", 1)}
+	res, err := diff.Apply(voters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Name != "Alice" || res[0].Weight != 3 || res[1].Name != "Carol" || res[1].Weight != 4 {
+		t.Errorf("Expected voters Alice: 3 and Carol: 4, got %v", res)
+	}
+	if voters[0].Weight != 2 {
+		t.Error("Apply must not change the original voters")
+	}
+	for _, invalid := range []string{"- Dave", "~ Dave: 1", "+ Bob: 1"} {
+		diff, err := ParseVotersDiff(strings.NewReader(invalid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = diff.Apply(voters); err == nil {
+			t.Errorf("Expected error when applying \"%s\"", invalid)
+		}
+	}
+	for _, invalid := range []string{"* Alice: 1", "+ Alice", "~ Alice: x", "-  "} {
+		if _, err := ParseVotersDiff(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected syntax error for \"%s\"", invalid)
+		}
+	}
+}
+
+func TestCompareVoters(t *testing.T) {
+	oldVoters := []*Voter{NewVoter("Bob", 1), NewVoter("Alice", 2), NewVoter("Dave", 3)}
+	newVoters := []*Voter{NewVoter("Alice", 4), NewVoter("Carol", 5), NewVoter("Dave", 3)}
+	res := CompareVoters(oldVoters, newVoters)
+	if len(res.Added) != 1 || res.Added[0].Name != "Carol" {
+		t.Errorf("Expected Carol to be added, got %v", res.Added)
+	}
+	if len(res.Removed) != 1 || res.Removed[0].Name != "Bob" {
+		t.Errorf("Expected Bob to be removed, got %v", res.Removed)
+	}
+	if len(res.Changed) != 1 || *res.Changed[0] != (WeightChange{"Alice", 2, 4}) {
+		t.Errorf("Expected weight of Alice to change from 2 to 4, got %v", res.Changed)
+	}
+	if res.OldWeight != 6 || res.NewWeight != 12 {
+		t.Errorf("Expected total weight 6 -> 12, got %d -> %d", res.OldWeight, res.NewWeight)
+	}
+}
+
+func TestParseVotingCollectionOrder(t *testing.T) {
+	text := `# StuRa: 09.05.2017
+## TOP 1
+### Wahl
+* A
+* Nein
+### Exkursion
+- 100
+### Stimmungsbild
+* Ja
+* Nein
+`
+	collection, err := ParseVotingCollection(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	votings := collection.Groups[0].Votings()
+	expected := []string{"Wahl", "Exkursion", "Stimmungsbild"}
+	if len(votings) != len(expected) {
+		t.Fatalf("Expected %d votings, got %d", len(expected), len(votings))
+	}
+	for i, voting := range votings {
+		if voting.Name() != expected[i] || voting.Position() != i {
+			t.Errorf("Expected voting \"%s\" at position %d, got \"%s\" at position %d", expected[i], i, voting.Name(), voting.Position())
+		}
+	}
+}
+
+func TestParsePercentRequired(t *testing.T) {
+	valid := map[string]float64{
+		"2/3":   2.0 / 3.0,
+		"1 / 2": 0.5,
+		"50%":   0.5,
+		"12,5%": 0.125,
+		"0.75":  0.75,
+		"0,75":  0.75,
+		"0":     0.0,
+	}
+	for str, expected := range valid {
+		percent, err := ParsePercentRequired(str)
+		if err != nil {
+			t.Errorf("Unexpected error for \"%s\": %s", str, err.Error())
+		} else if percent != expected {
+			t.Errorf("Expected %v for \"%s\", got %v", expected, str, percent)
+		}
+	}
+	// a majority of 1 can never be exceeded
+	for _, str := range []string{"", "3/2", "1/0", "a/3", "101%", "-0.5", "1.5", "NaN", "abc", "1", "1/1", "100%"} {
+		if _, err := ParsePercentRequired(str); err == nil {
+			t.Errorf("Expected error for \"%s\"", str)
+		}
+	}
+	if percent, _ := ParsePercentRequired(FormatPercentRequired(2.0 / 3.0)); percent != 2.0/3.0 {
+		t.Errorf("Expected FormatPercentRequired to be parsed back to 2/3, got %v", percent)
+	}
+}
+
+func TestParseQuorum(t *testing.T) {
+	for str, expected := range map[string]float64{"1": 1.0, "100%": 1.0, "1/2": 0.5} {
+		if quorum, err := ParseQuorum(str); err != nil || quorum != expected {
+			t.Errorf("Expected quorum %v for \"%s\", got %v (error %v)", expected, str, quorum, err)
+		}
+	}
+	for _, str := range []string{"3/2", "-0.5", "NaN"} {
+		if _, err := ParseQuorum(str); err == nil {
+			t.Errorf("Expected error for quorum \"%s\"", str)
+		}
+	}
+}
+
+func TestParseVotingCollectionMajority(t *testing.T) {
+	text := `# StuRa: 09.05.2017
+majority: 50%
+## TOP 1
+majority: 2/3
+### Satzung
+* Ja
+* Nein
+### Geld
+majority: 0.6
+- 100
+## TOP 2
+### Wahl
+* A
+* Nein
+`
+	collection, err := ParseVotingCollection(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	top1, top2 := collection.Groups[0], collection.Groups[1]
+	if percent := top1.SchulzeVotings[0].PercentRequired; percent != 2.0/3.0 {
+		t.Errorf("Expected group default 2/3, got %v", percent)
+	}
+	if percent := top1.MedianVotings[0].PercentRequired; percent != 0.6 {
+		t.Errorf("Expected 0.6 set for the voting, got %v", percent)
+	}
+	if percent := top2.SchulzeVotings[0].PercentRequired; percent != 0.5 {
+		t.Errorf("Expected collection default 0.5, got %v", percent)
+	}
+	invalid := []string{
+		"# StuRa: 09.05.2017\nmajority: 1/2\nmajority: 2/3\n",
+		"# StuRa: 09.05.2017\n## TOP\n### Wahl\n* A\nmajority: 2/3\n",
+		"# StuRa: 09.05.2017\n## TOP\n### Wahl\nmajority: 3/2\n* A\n",
+	}
+	for _, str := range invalid {
+		if _, err := ParseVotingCollection(strings.NewReader(str)); err == nil {
+			t.Errorf("Expected error when parsing\n%s", str)
+		}
+	}
+}
+
+func TestParseStatusQuo(t *testing.T) {
+	text := `# StuRa: 09.05.2017
+## TOP 1
+### Wahl
+*! Nein
+* A
+* B
+### Stimmungsbild
+* Ja
+* Nein
+`
+	collection, err := ParseVotingCollection(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	votings := collection.Groups[0].SchulzeVotings
+	if votings[0].StatusQuo != "Nein" || votings[0].StatusQuoIndex() != 0 {
+		t.Errorf("Expected status quo \"Nein\" at index 0, got \"%s\"", votings[0].StatusQuo)
+	}
+	if votings[1].StatusQuo != "" || votings[1].StatusQuoIndex() != 1 {
+		t.Errorf("Expected the last option as default status quo, got \"%s\"", votings[1].StatusQuo)
+	}
+	invalid := "# StuRa: 09.05.2017\n## TOP\n### Wahl\n*! A\n*! Nein\n"
+	if _, err := ParseVotingCollection(strings.NewReader(invalid)); err == nil {
+		t.Error("Expected error for two status quo options")
+	}
+}
+
+func TestParseDuplicateOptions(t *testing.T) {
+	invalid := []string{
+		"# StuRa: 09.05.2017\n## TOP\n### Wahl\n* A\n* B\n* A\n",
+		"# StuRa: 09.05.2017\n## TOP\n### Wahl\n* Nein\n*! Nein\n",
+	}
+	for _, str := range invalid {
+		if _, err := ParseVotingCollection(strings.NewReader(str)); err == nil {
+			t.Errorf("Expected error for duplicate options when parsing\n%s", str)
+		}
+	}
+	// the same option in different votings is fine
+	valid := "# StuRa: 09.05.2017\n## TOP\n### Wahl\n* A\n* Nein\n### Satzung\n* Ja\n* Nein\n"
+	if _, err := ParseVotingCollection(strings.NewReader(valid)); err != nil {
+		t.Errorf("Expected no error for the same option in different votings, got %v", err)
+	}
+}
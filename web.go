@@ -0,0 +1,948 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sessionName is the name of the cookie that stores the session.
+const sessionName = "sturavoting-session"
+
+// sessionUserKey is the key in the session values that stores the id of the
+// user that is logged in.
+const sessionUserKey = "user"
+
+// sessionCSRFKey is the key in the session values that stores the CSRF
+// token of the session, it is created on login.
+const sessionCSRFKey = "csrf"
+
+// csrfFormKey is the name of the form value that must contain the CSRF token
+// in each POST request, see checkCSRF.
+const csrfFormKey = "csrf"
+
+// templateNames contains all templates that are loaded by ReadTemplates,
+// each template is loaded from TemplateDir/<name>.html together with
+// TemplateDir/base.html.
+var templateNames = []string{"login", "index", "category", "revision",
+	"collection", "median_result", "schulze_result", "median_ballot",
+	"schulze_ballot", "evaluation_options"}
+
+// templateFuncs are the functions available in all templates.
+var templateFuncs = template.FuncMap{
+	"money":   FormatConcurrency,
+	"percent": func(f float64) string { return fmt.Sprintf("%.2f%%", f*100.0) },
+}
+
+// ReadTemplates parses all templates from dir and stores them in Templates.
+func (context *VotingContext) ReadTemplates(dir string) error {
+	base := filepath.Join(dir, "base.html")
+	for _, name := range templateNames {
+		tmpl, err := template.New(name).Funcs(templateFuncs).ParseFiles(base, filepath.Join(dir, name+".html"))
+		if err != nil {
+			return err
+		}
+		context.Templates[name] = tmpl
+	}
+	return nil
+}
+
+// pageData is the data passed to each template. Data contains the
+// page specific data, each form that is posted must contain CSRFToken as
+// the form value "csrf".
+type pageData struct {
+	UserName  string
+	IsAdmin   bool
+	CSRFToken string
+	Data      interface{}
+}
+
+// render executes the template with the given name, the template must be
+// loaded with ReadTemplates.
+func (context *VotingContext) render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	tmpl, has := context.Templates[name]
+	if !has {
+		context.Logger.WithField("template", name).Error("Template not found")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	page := pageData{Data: data, CSRFToken: context.csrfToken(r)}
+	if userID, ok := context.currentUser(r); ok {
+		if userName, err := context.UserHandler.GetUserName(userID); err == nil {
+			page.UserName = userName
+		}
+		if isAdmin, err := context.Storage.IsAdmin(userID); err == nil {
+			page.IsAdmin = isAdmin
+		}
+	}
+	if err := tmpl.ExecuteTemplate(w, "base", page); err != nil {
+		context.Logger.WithError(err).WithField("template", name).Error("Can't execute template")
+	}
+}
+
+// serverError logs the error and writes an internal server error.
+func (context *VotingContext) serverError(w http.ResponseWriter, err error) {
+	context.Logger.WithError(err).Error("Error while handling request")
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// storageError writes a not found error if err is sql.ErrNoRows, i.e. the
+// requested object doesn't exist, and an internal server error otherwise.
+func (context *VotingContext) storageError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	context.serverError(w, err)
+}
+
+// currentUser returns the id of the user that is logged in, the boolean is
+// false if no user is logged in.
+func (context *VotingContext) currentUser(r *http.Request) (uint64, bool) {
+	session, err := context.Store.Get(r, sessionName)
+	if err != nil {
+		return 0, false
+	}
+	userID, ok := session.Values[sessionUserKey].(uint64)
+	return userID, ok
+}
+
+// csrfToken returns the CSRF token of the session, the empty string if
+// there is none.
+func (context *VotingContext) csrfToken(r *http.Request) string {
+	session, err := context.Store.Get(r, sessionName)
+	if err != nil {
+		return ""
+	}
+	token, _ := session.Values[sessionCSRFKey].(string)
+	return token
+}
+
+// newCSRFToken returns a new random CSRF token.
+func newCSRFToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// VotingHandler is a http handler function that has access to the
+// VotingContext.
+type VotingHandler func(context *VotingContext, w http.ResponseWriter, r *http.Request)
+
+// requireLogin returns a http.HandlerFunc that redirects to the login page
+// if no user is logged in and calls handler otherwise.
+func (context *VotingContext) requireLogin(handler VotingHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := context.currentUser(r); !ok {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		handler(context, w, r)
+	}
+}
+
+// requireAdmin returns a http.HandlerFunc that calls handler only if the
+// user that is logged in is an administrator.
+func (context *VotingContext) requireAdmin(handler VotingHandler) http.HandlerFunc {
+	return context.requireLogin(func(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+		userID, _ := context.currentUser(r)
+		isAdmin, err := context.Storage.IsAdmin(userID)
+		if err != nil {
+			context.serverError(w, err)
+			return
+		}
+		if !isAdmin {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler(context, w, r)
+	})
+}
+
+// checkCSRF returns a handler that calls handler only if the request is not
+// a POST request or the form value "csrf" is the CSRF token of the session.
+func (context *VotingContext) checkCSRF(handler VotingHandler) VotingHandler {
+	return func(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			token := context.csrfToken(r)
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(r.PostFormValue(csrfFormKey))) != 1 {
+				http.Error(w, "Invalid CSRF token, please reload the page or log in again", http.StatusForbidden)
+				return
+			}
+		}
+		handler(context, w, r)
+	}
+}
+
+// parseID parses the uint value stored in the form value with the given key.
+func parseID(r *http.Request, key string) (uint, error) {
+	str := r.FormValue(key)
+	if str == "" {
+		return InvalidID, fmt.Errorf("Missing parameter \"%s\"", key)
+	}
+	id, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return InvalidID, fmt.Errorf("Invalid value for \"%s\": %s", key, err.Error())
+	}
+	return uint(id), nil
+}
+
+// parseEvaluationOptions reads the evaluation options from the parameters
+// "basis", "present", "quorum", "majority" (the default majority), "ties" and
+// "seed", all parameters are optional. It returns an error if the options
+// are invalid, see EvaluationOptions.Validate.
+func parseEvaluationOptions(r *http.Request) (*EvaluationOptions, error) {
+	res := DefaultEvaluationOptions()
+	var err error
+	if str := r.FormValue("basis"); str != "" {
+		if res.Basis, err = ParseMajorityBasis(str); err != nil {
+			return nil, err
+		}
+	}
+	if str := r.FormValue("present"); str != "" {
+		if res.Present, err = strconv.Atoi(str); err != nil {
+			return nil, fmt.Errorf("Invalid value for \"present\": %s", err.Error())
+		}
+	}
+	if str := r.FormValue("quorum"); str != "" {
+		if res.Quorum, err = ParseQuorum(str); err != nil {
+			return nil, err
+		}
+	}
+	if str := r.FormValue("majority"); str != "" {
+		percent, err := ParsePercentRequired(str)
+		if err != nil {
+			return nil, err
+		}
+		majority := Majority(percent)
+		res.DefaultMajority = &majority
+	}
+	if str := r.FormValue("ties"); str != "" {
+		mode, err := ParseTieBreaking(str)
+		if err != nil {
+			return nil, err
+		}
+		res.TieBreaker = &TieBreaker{Mode: mode}
+		if str = r.FormValue("seed"); str != "" {
+			if res.TieBreaker.Seed, err = strconv.ParseInt(str, 10, 64); err != nil {
+				return nil, fmt.Errorf("Invalid value for \"seed\": %s", err.Error())
+			}
+		}
+	}
+	if err = res.Validate(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// NewServeMux returns the http handler for the web application.
+func (context *VotingContext) NewServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		loginHandler(context, w, r)
+	})
+	mux.HandleFunc("/logout", context.requireLogin(context.checkCSRF(logoutHandler)))
+	mux.HandleFunc("/", context.requireLogin(indexHandler))
+	mux.HandleFunc("/category", context.requireAdmin(categoryHandler))
+	mux.HandleFunc("/category/add", context.requireAdmin(context.checkCSRF(addCategoryHandler)))
+	mux.HandleFunc("/revision", context.requireAdmin(revisionHandler))
+	mux.HandleFunc("/revision/add", context.requireAdmin(context.checkCSRF(addRevisionHandler)))
+	mux.HandleFunc("/revision/clone", context.requireAdmin(context.checkCSRF(cloneRevisionHandler)))
+	mux.HandleFunc("/revision/upload", context.requireAdmin(context.checkCSRF(uploadVotersHandler)))
+	mux.HandleFunc("/revision/link", context.requireAdmin(context.checkCSRF(linkUserHandler)))
+	mux.HandleFunc("/revision/unlink", context.requireAdmin(context.checkCSRF(unlinkUserHandler)))
+	mux.HandleFunc("/collection", context.requireLogin(collectionHandler))
+	mux.HandleFunc("/collection/upload", context.requireAdmin(context.checkCSRF(uploadCollectionHandler)))
+	mux.HandleFunc("/median", context.requireAdmin(medianResultHandler))
+	mux.HandleFunc("/schulze", context.requireAdmin(schulzeResultHandler))
+	mux.HandleFunc("/vote/median", context.requireLogin(context.checkCSRF(medianBallotHandler)))
+	mux.HandleFunc("/vote/schulze", context.requireLogin(context.checkCSRF(schulzeBallotHandler)))
+	return mux
+}
+
+// ListenAndServe reads the templates from templateDir and starts the web
+// application on the configured port.
+func (context *VotingContext) ListenAndServe(templateDir string) error {
+	if err := context.ReadTemplates(templateDir); err != nil {
+		return err
+	}
+	addr := fmt.Sprintf(":%d", context.Port)
+	context.Logger.WithField("port", context.Port).Info("Starting web server")
+	return http.ListenAndServe(addr, context.NewServeMux())
+}
+
+func loginHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		context.render(w, r, "login", nil)
+		return
+	}
+	userName := strings.TrimSpace(r.PostFormValue("username"))
+	password := r.PostFormValue("password")
+	userID, err := context.UserHandler.Validate(userName, []byte(password))
+	if err != nil {
+		context.Logger.WithField("user", userName).Info("Failed login attempt")
+		w.WriteHeader(http.StatusUnauthorized)
+		context.render(w, r, "login", "Invalid username or password")
+		return
+	}
+	token, err := newCSRFToken()
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	// ignore the error, if the cookie is invalid we get a new session anyway
+	session, _ := context.Store.Get(r, sessionName)
+	// don't keep anything from the session before the login, stores that
+	// keep the session on the server create a new id for an empty id
+	for key := range session.Values {
+		delete(session.Values, key)
+	}
+	session.ID = ""
+	session.Values[sessionUserKey] = userID
+	session.Values[sessionCSRFKey] = token
+	session.Options.MaxAge = int(context.SessionLifespan.Seconds())
+	if err := session.Save(r, w); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func logoutHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := context.Store.Get(r, sessionName)
+	delete(session.Values, sessionUserKey)
+	delete(session.Values, sessionCSRFKey)
+	session.Options.MaxAge = -1
+	if err := session.Save(r, w); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+func indexHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	userID, _ := context.currentUser(r)
+	isAdmin, err := context.Storage.IsAdmin(userID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	data := struct {
+		Categories  []*Category
+		Collections []*VotingCollection
+	}{}
+	if isAdmin {
+		data.Categories, err = context.Storage.ListCategories()
+	} else {
+		data.Collections, err = context.Storage.ListUserCollections(userID)
+	}
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	context.render(w, r, "index", data)
+}
+
+func addCategoryHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if err := validateVotingsString(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := context.Storage.InsertCategory(name); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func categoryHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	categoryID, err := parseID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	revisions, err := context.Storage.ListVotersRevision(categoryID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	data := struct {
+		CategoryID uint
+		Revisions  []*VotersRevision
+	}{categoryID, revisions}
+	context.render(w, r, "category", data)
+}
+
+func addRevisionHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	categoryID, err := parseID(r, "category")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := context.Storage.InsertVotersRevision(categoryID); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/category?id=%d", categoryID), http.StatusFound)
+}
+
+func revisionHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	revisionID, err := parseID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	voters, err := context.Storage.ListVoters(revisionID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	collections, err := context.Storage.ListVotingCollections(revisionID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	voterUsers, err := context.Storage.ListVoterUsers(revisionID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	userNames, err := context.UserHandler.ListUsers()
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	weightSum := 0
+	// maps voter ids to the names of the users linked to the voter
+	linkedUsers := make(map[uint][]string, len(voterUsers))
+	for _, voter := range voters {
+		weightSum += voter.Weight
+		for _, userID := range voterUsers[voter.ID] {
+			linkedUsers[voter.ID] = append(linkedUsers[voter.ID], userNames[userID])
+		}
+	}
+	data := struct {
+		RevisionID  uint
+		Voters      []*Voter
+		WeightSum   int
+		LinkedUsers map[uint][]string
+		Collections []*VotingCollection
+	}{revisionID, voters, weightSum, linkedUsers, collections}
+	context.render(w, r, "revision", data)
+}
+
+// cloneRevisionHandler creates a copy of a revision, the changes to apply to
+// the voters can be uploaded in an optional diff file (see
+// ParseVotersDiff).
+func cloneRevisionHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revisionID, err := parseID(r, "revision")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	diff := NewVotersDiff()
+	file, _, err := r.FormFile("file")
+	switch {
+	case err == http.ErrMissingFile:
+		// no changes
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		defer file.Close()
+		if diff, err = ParseVotersDiff(file); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	newID, err := context.Storage.CloneVotersRevision(revisionID, diff)
+	if _, ok := err.(*DiffError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/revision?id=%d", newID), http.StatusFound)
+}
+
+func uploadVotersHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revisionID, err := parseID(r, "revision")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	voters, err := ParseVoters(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := context.Storage.ListVoters(revisionID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	names := make(map[string]bool, len(existing))
+	for _, voter := range existing {
+		names[voter.Name] = true
+	}
+	for _, voter := range voters {
+		if names[voter.Name] {
+			http.Error(w, fmt.Sprintf("Voter \"%s\" exists already in revision %d", voter.Name, revisionID), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := context.Storage.InsertVoters(revisionID, voters); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/revision?id=%d", revisionID), http.StatusFound)
+}
+
+func linkUserHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revisionID, err := parseID(r, "revision")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	voterID, err := parseID(r, "voter")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	voters, err := context.Storage.ListVoters(revisionID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	inRevision := false
+	for _, voter := range voters {
+		if voter.ID == voterID {
+			inRevision = true
+			break
+		}
+	}
+	if !inRevision {
+		http.Error(w, fmt.Sprintf("There is no voter with id %d in revision %d", voterID, revisionID), http.StatusBadRequest)
+		return
+	}
+	userID, err := context.UserIDByName(strings.TrimSpace(r.PostFormValue("username")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := context.Storage.LinkUserToVoter(userID, voterID); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/revision?id=%d", revisionID), http.StatusFound)
+}
+
+func unlinkUserHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revisionID, err := parseID(r, "revision")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID, err := context.UserIDByName(strings.TrimSpace(r.PostFormValue("username")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := context.Storage.UnlinkUser(userID, revisionID); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/revision?id=%d", revisionID), http.StatusFound)
+}
+
+func uploadCollectionHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revisionID, err := parseID(r, "revision")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	collection, err := ParseVotingCollection(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := context.Storage.InsertVotingCollection(revisionID, collection); err != nil {
+		context.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/collection?id=%d", collection.ID), http.StatusFound)
+}
+
+func collectionHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	collectionID, err := parseID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	collection, err := context.Storage.GetVotingCollection(collectionID)
+	if err != nil {
+		context.storageError(w, r, err)
+		return
+	}
+	// only administrators and voters of the collection may see it
+	userID, _ := context.currentUser(r)
+	isAdmin, err := context.Storage.IsAdmin(userID)
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	if !isAdmin {
+		_, err = context.Storage.GetUserVoter(userID, collection.VotersID)
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		case err != nil:
+			context.serverError(w, err)
+			return
+		}
+	}
+	context.render(w, r, "collection", collection)
+}
+
+// evaluationOptionsData is the data for the evaluation_options template.
+type evaluationOptionsData struct {
+	Action  string
+	ID      uint
+	Schulze bool
+	Error   string
+}
+
+// evaluationOptionsError writes a bad request error and renders the form for
+// the evaluation options of the voting with the error (invalid options or a
+// missing majority), action is the path of the result handler.
+func (context *VotingContext) evaluationOptionsError(w http.ResponseWriter, r *http.Request, action string, votingID uint, err error) {
+	data := evaluationOptionsData{Action: action, ID: votingID,
+		Schulze: action == "/schulze", Error: err.Error()}
+	w.WriteHeader(http.StatusBadRequest)
+	context.render(w, r, "evaluation_options", data)
+}
+
+func medianResultHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	votingID, err := parseID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, err := parseEvaluationOptions(r)
+	if err != nil {
+		context.evaluationOptionsError(w, r, "/median", votingID, err)
+		return
+	}
+	res, err := EvaluateMedianVoting(context.Storage, votingID, options)
+	switch err.(type) {
+	case nil:
+	case *MajorityError, *EvaluationOptionsError:
+		context.evaluationOptionsError(w, r, "/median", votingID, err)
+		return
+	default:
+		context.storageError(w, r, err)
+		return
+	}
+	context.render(w, r, "median_result", res)
+}
+
+func schulzeResultHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	votingID, err := parseID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, err := parseEvaluationOptions(r)
+	if err != nil {
+		context.evaluationOptionsError(w, r, "/schulze", votingID, err)
+		return
+	}
+	res, err := EvaluateSchulzeVoting(context.Storage, votingID, options)
+	switch err.(type) {
+	case nil:
+	case *MajorityError, *EvaluationOptionsError:
+		context.evaluationOptionsError(w, r, "/schulze", votingID, err)
+		return
+	default:
+		context.storageError(w, r, err)
+		return
+	}
+	context.render(w, r, "schulze_result", res)
+}
+
+// voterForRequest returns the voter in the revision with id revisionID the
+// user that is logged in votes for, see LinkUserToVoter.
+// If the user is no voter in the revision it returns sql.ErrNoRows.
+func (context *VotingContext) voterForRequest(r *http.Request, revisionID uint) (*Voter, error) {
+	userID, ok := context.currentUser(r)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return context.Storage.GetUserVoter(userID, revisionID)
+}
+
+// medianBallotData is the data for the median_ballot template.
+type medianBallotData struct {
+	Voting  *MedianVoting
+	Voter   *Voter
+	Value   string
+	Abstain bool
+	Error   string
+	Saved   bool
+}
+
+func medianBallotHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	votingID, err := parseID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	voting, err := context.Storage.GetMedianVoting(votingID)
+	if err != nil {
+		context.storageError(w, r, err)
+		return
+	}
+	revisionID, err := context.Storage.GetMedianVotingRevision(votingID)
+	if err != nil {
+		context.storageError(w, r, err)
+		return
+	}
+	voter, err := context.voterForRequest(r, revisionID)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "You are not allowed to vote in this voting", http.StatusForbidden)
+		return
+	case err != nil:
+		context.serverError(w, err)
+		return
+	}
+	oldValue, oldAbstain, err := context.Storage.GetMedianVote(votingID, voter.ID)
+	hasVoted := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		context.serverError(w, err)
+		return
+	}
+	data := medianBallotData{Voting: voting, Voter: voter}
+	if hasVoted {
+		data.Abstain = oldAbstain
+		if !oldAbstain {
+			data.Value = FormatConcurrency(oldValue)
+		}
+	}
+	if r.Method != http.MethodPost {
+		context.render(w, r, "median_ballot", data)
+		return
+	}
+	data.Value = r.PostFormValue("value")
+	data.Abstain = r.PostFormValue("abstain") != ""
+	value := 0
+	if !data.Abstain {
+		value, err = ParseMedianValue(data.Value, voting)
+		if err != nil {
+			data.Error = err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+			context.render(w, r, "median_ballot", data)
+			return
+		}
+	}
+	if hasVoted {
+		err = context.Storage.UpdateMedianVote(votingID, voter.ID, value, data.Abstain)
+	} else {
+		err = context.Storage.InsertMedianVote(votingID, voter.ID, value, data.Abstain)
+	}
+	if err != nil {
+		context.storageError(w, r, err)
+		return
+	}
+	data.Value = ""
+	if !data.Abstain {
+		data.Value = FormatConcurrency(value)
+	}
+	data.Saved = true
+	context.render(w, r, "median_ballot", data)
+}
+
+// schulzeBallotOption is an option together with the position entered by the
+// voter.
+type schulzeBallotOption struct {
+	Index    int
+	Name     string
+	Position string
+}
+
+// schulzeBallotData is the data for the schulze_ballot template.
+type schulzeBallotData struct {
+	Voting  *SchulzeVoting
+	Voter   *Voter
+	Options []schulzeBallotOption
+	Abstain bool
+	Error   string
+	Saved   bool
+}
+
+func schulzeBallotHandler(context *VotingContext, w http.ResponseWriter, r *http.Request) {
+	votingID, err := parseID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	voting, err := context.Storage.GetSchulzeVoting(votingID)
+	if err != nil {
+		context.storageError(w, r, err)
+		return
+	}
+	revisionID, err := context.Storage.GetSchulzeVotingRevision(votingID)
+	if err != nil {
+		context.storageError(w, r, err)
+		return
+	}
+	voter, err := context.voterForRequest(r, revisionID)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "You are not allowed to vote in this voting", http.StatusForbidden)
+		return
+	case err != nil:
+		context.serverError(w, err)
+		return
+	}
+	oldRanking, err := context.Storage.GetSchulzeVote(votingID, voter.ID)
+	hasVoted := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		context.serverError(w, err)
+		return
+	}
+	data := schulzeBallotData{Voting: voting, Voter: voter,
+		Options: make([]schulzeBallotOption, len(voting.Options))}
+	for i, option := range voting.Options {
+		data.Options[i] = schulzeBallotOption{Index: i, Name: option}
+		if hasVoted && oldRanking[i] != Unranked {
+			data.Options[i].Position = strconv.Itoa(oldRanking[i])
+		}
+	}
+	data.Abstain = hasVoted && RankingAbstains(oldRanking)
+	if r.Method != http.MethodPost {
+		context.render(w, r, "schulze_ballot", data)
+		return
+	}
+	positions := make([]string, len(voting.Options))
+	ranked := false
+	for i := range voting.Options {
+		positions[i] = r.PostFormValue(fmt.Sprintf("rank-%d", i))
+		data.Options[i].Position = positions[i]
+		ranked = ranked || strings.TrimSpace(positions[i]) != ""
+	}
+	data.Abstain = r.PostFormValue("abstain") != ""
+	var ranking []int
+	switch {
+	case data.Abstain && ranked:
+		err = errors.New("You can't both rank options and abstain")
+	case data.Abstain:
+		// an abstention is stored as a ranking without ranked options
+		ranking = make([]int, len(voting.Options))
+		for i := range ranking {
+			ranking[i] = Unranked
+		}
+	default:
+		ranking, err = ParseSchulzeRanking(positions, voting)
+	}
+	if err != nil {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		context.render(w, r, "schulze_ballot", data)
+		return
+	}
+	if hasVoted {
+		err = context.Storage.UpdateSchulzeVote(votingID, voter.ID, ranking)
+	} else {
+		err = context.Storage.InsertSchulzeVote(votingID, voter.ID, ranking)
+	}
+	if err != nil {
+		context.serverError(w, err)
+		return
+	}
+	data.Saved = true
+	context.render(w, r, "schulze_ballot", data)
+}
@@ -0,0 +1,682 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FabianWe/goauth"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+)
+
+// testPassword is the password of all users of testUserHandler.
+const testPassword = "secret"
+
+// testUserHandler is a goauth.UserHandler with a fixed set of users, only
+// the methods used by the web application are implemented.
+type testUserHandler struct {
+	goauth.UserHandler
+	users map[uint64]string
+}
+
+func (handler *testUserHandler) Validate(userName string, password []byte) (uint64, error) {
+	for id, name := range handler.users {
+		if name == userName && string(password) == testPassword {
+			return id, nil
+		}
+	}
+	return 0, errors.New("Invalid username or password")
+}
+
+func (handler *testUserHandler) GetUserName(userID uint64) (string, error) {
+	name, has := handler.users[userID]
+	if !has {
+		return "", fmt.Errorf("No user with id %d", userID)
+	}
+	return name, nil
+}
+
+func (handler *testUserHandler) ListUsers() (map[uint64]string, error) {
+	return handler.users, nil
+}
+
+// webTest is a running web application with a MemoryStorage that contains
+// a collection with one median and one schulze voting.
+// The user "admin" is an administrator, "alice" votes for the voter Alice
+// and "mallory" is no voter.
+type webTest struct {
+	server     *httptest.Server
+	storage    Storage
+	collection *VotingCollection
+	median     *MedianVoting
+	schulze    *SchulzeVoting
+	revisionID uint
+	aliceID    uint
+}
+
+func newWebTest(t *testing.T) *webTest {
+	storage := NewMemoryStorage()
+	users := map[uint64]string{1: "admin", 2: "alice", 3: "mallory"}
+	if err := storage.SetAdmin(1, true); err != nil {
+		t.Fatal(err)
+	}
+	categoryID, err := storage.InsertCategory("StuRa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisionID, err := storage.InsertVotersRevision(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertVoters(revisionID, []*Voter{NewVoter("Alice", 2), NewVoter("Bob", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := storage.GetVoterByName(revisionID, "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.LinkUserToVoter(2, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	median := &MedianVoting{Name: "Budget", MaxValue: 1000, PercentRequired: 0.5}
+	schulze := &SchulzeVoting{Name: "Chair", Options: []string{"A", "B", "No"}, PercentRequired: 0.5, Position: 1}
+	collection := &VotingCollection{Name: "Meeting", Date: time.Date(2017, time.May, 9, 0, 0, 0, 0, time.UTC),
+		Groups: []*VotingGroup{&VotingGroup{Name: "Finances",
+			MedianVotings: []*MedianVoting{median}, SchulzeVotings: []*SchulzeVoting{schulze}}}}
+	if err = storage.InsertVotingCollection(revisionID, collection); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	context := &VotingContext{Storage: storage, Logger: logger,
+		Store:           sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32)),
+		UserHandler:     &testUserHandler{users: users},
+		Templates:       make(map[string]*template.Template),
+		SessionLifespan: time.Hour}
+	if err = context.ReadTemplates("templates"); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(context.NewServeMux())
+	return &webTest{server: server, storage: storage, collection: collection,
+		median: median, schulze: schulze, revisionID: revisionID, aliceID: alice.ID}
+}
+
+// client returns a client that doesn't follow redirects and is logged in as
+// the given user, no user is logged in if userName is empty.
+func (test *webTest) client(t *testing.T, userName string) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+	if userName == "" {
+		return client
+	}
+	resp, err := client.PostForm(test.server.URL+"/login", url.Values{"username": {userName}, "password": {testPassword}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected redirect after logging in as %s, got status %d", userName, resp.StatusCode)
+	}
+	return client
+}
+
+var csrfInput = regexp.MustCompile(`name="csrf" value="([^"]+)"`)
+
+// get requests the page and returns the status code and the CSRF token in
+// the page (empty if there is none).
+func (test *webTest) get(t *testing.T, client *http.Client, path string) (int, string) {
+	status, body := test.page(t, client, path)
+	token := ""
+	if match := csrfInput.FindStringSubmatch(body); match != nil {
+		token = match[1]
+	}
+	return status, token
+}
+
+// page requests the page and returns the status code and the body.
+func (test *webTest) page(t *testing.T, client *http.Client, path string) (int, string) {
+	resp, err := client.Get(test.server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// post posts the form and returns the status code.
+func (test *webTest) post(t *testing.T, client *http.Client, path string, form url.Values) int {
+	resp, err := client.PostForm(test.server.URL+path, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// upload posts the form with content as the form file "file" and returns the
+// status code.
+func (test *webTest) upload(t *testing.T, client *http.Client, path string, form url.Values, content string) int {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, values := range form {
+		for _, value := range values {
+			if err := writer.WriteField(key, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	file, err := writer.CreateFormFile("file", "upload.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Post(test.server.URL+path, writer.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWebPermissions(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	anonymous := test.client(t, "")
+	if status, _ := test.get(t, anonymous, "/"); status != http.StatusFound {
+		t.Errorf("Expected redirect to the login page, got status %d", status)
+	}
+	medianPath := fmt.Sprintf("/vote/median?id=%d", test.median.ID)
+	if status := test.post(t, anonymous, medianPath, url.Values{"value": {"1"}}); status != http.StatusFound {
+		t.Errorf("Expected redirect to the login page when voting without login, got status %d", status)
+	}
+	if status := test.post(t, anonymous, "/login", url.Values{"username": {"alice"}, "password": {"wrong"}}); status != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a wrong password, got %d", http.StatusUnauthorized, status)
+	}
+
+	alice := test.client(t, "alice")
+	if status, _ := test.get(t, alice, "/"); status != http.StatusOK {
+		t.Errorf("Expected status %d for the index page, got %d", http.StatusOK, status)
+	}
+	for _, path := range []string{"/category?id=1", "/revision?id=1"} {
+		if status, _ := test.get(t, alice, path); status != http.StatusForbidden {
+			t.Errorf("Expected status %d for %s as voter, got %d", http.StatusForbidden, path, status)
+		}
+	}
+	_, token := test.get(t, alice, medianPath)
+	if token == "" {
+		t.Fatal("Expected a CSRF token in the ballot form")
+	}
+	form := url.Values{"csrf": {token}, "revision": {"1"}, "username": {"alice"}, "voter": {"2"}}
+	if status := test.post(t, alice, "/revision/link", form); status != http.StatusForbidden {
+		t.Errorf("Expected status %d when a voter links users, got %d", http.StatusForbidden, status)
+	}
+
+	collectionPath := fmt.Sprintf("/collection?id=%d", test.collection.ID)
+	if status, _ := test.get(t, alice, collectionPath); status != http.StatusOK {
+		t.Errorf("Expected status %d for the collection as voter, got %d", http.StatusOK, status)
+	}
+	resultPaths := []string{fmt.Sprintf("/median?id=%d", test.median.ID), fmt.Sprintf("/schulze?id=%d", test.schulze.ID)}
+	for _, path := range resultPaths {
+		if status, _ := test.get(t, alice, path); status != http.StatusForbidden {
+			t.Errorf("Expected status %d for %s as voter, got %d", http.StatusForbidden, path, status)
+		}
+	}
+
+	mallory := test.client(t, "mallory")
+	for _, path := range append([]string{collectionPath, medianPath, fmt.Sprintf("/vote/schulze?id=%d", test.schulze.ID)}, resultPaths...) {
+		if status, _ := test.get(t, mallory, path); status != http.StatusForbidden {
+			t.Errorf("Expected status %d for %s as no voter, got %d", http.StatusForbidden, path, status)
+		}
+	}
+
+	admin := test.client(t, "admin")
+	for _, path := range append([]string{"/category?id=1", "/revision?id=1", collectionPath}, resultPaths...) {
+		if status, _ := test.get(t, admin, path); status != http.StatusOK {
+			t.Errorf("Expected status %d for %s as admin, got %d", http.StatusOK, path, status)
+		}
+	}
+	// the admin is no voter in the revision
+	if status, _ := test.get(t, admin, medianPath); status != http.StatusForbidden {
+		t.Errorf("Expected status %d for the ballot as admin, got %d", http.StatusForbidden, status)
+	}
+}
+
+func TestWebMedianBallot(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	alice := test.client(t, "alice")
+	path := fmt.Sprintf("/vote/median?id=%d", test.median.ID)
+	status, token := test.get(t, alice, path)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d for the ballot, got %d", http.StatusOK, status)
+	}
+
+	// posts without the CSRF token of the session are rejected
+	if status = test.post(t, alice, path, url.Values{"value": {"5"}}); status != http.StatusForbidden {
+		t.Errorf("Expected status %d without CSRF token, got %d", http.StatusForbidden, status)
+	}
+	if status = test.post(t, alice, path, url.Values{"csrf": {"invalid"}, "value": {"5"}}); status != http.StatusForbidden {
+		t.Errorf("Expected status %d for an invalid CSRF token, got %d", http.StatusForbidden, status)
+	}
+	if _, _, err := test.storage.GetMedianVote(test.median.ID, test.aliceID); err == nil {
+		t.Error("Expected no vote to be stored without a valid CSRF token")
+	}
+
+	if status = test.post(t, alice, path, url.Values{"csrf": {token}, "value": {"11"}}); status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a value greater than the maximum, got %d", http.StatusBadRequest, status)
+	}
+	if status = test.post(t, alice, path, url.Values{"csrf": {token}, "value": {"5,50"}}); status != http.StatusOK {
+		t.Fatalf("Expected status %d when casting the vote, got %d", http.StatusOK, status)
+	}
+	value, abstain, err := test.storage.GetMedianVote(test.median.ID, test.aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != 550 || abstain {
+		t.Errorf("Expected vote for 550 after casting the vote, got %d (abstain %v)", value, abstain)
+	}
+
+	if status = test.post(t, alice, path, url.Values{"csrf": {token}, "value": {"7"}}); status != http.StatusOK {
+		t.Fatalf("Expected status %d when updating the vote, got %d", http.StatusOK, status)
+	}
+	if value, _, err = test.storage.GetMedianVote(test.median.ID, test.aliceID); err != nil || value != 700 {
+		t.Errorf("Expected vote for 700 after updating the vote, got %d (error %v)", value, err)
+	}
+	if status = test.post(t, alice, path, url.Values{"csrf": {token}, "abstain": {"on"}}); status != http.StatusOK {
+		t.Fatalf("Expected status %d when abstaining, got %d", http.StatusOK, status)
+	}
+	if _, abstain, err = test.storage.GetMedianVote(test.median.ID, test.aliceID); err != nil || !abstain {
+		t.Errorf("Expected an abstention after updating the vote, got %v (error %v)", abstain, err)
+	}
+	votes, err := test.storage.GetMedianVotes(test.median.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(votes) != 1 {
+		t.Errorf("Expected one vote after updating the vote, got %d", len(votes))
+	}
+}
+
+func TestWebSchulzeBallot(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	alice := test.client(t, "alice")
+	path := fmt.Sprintf("/vote/schulze?id=%d", test.schulze.ID)
+	status, token := test.get(t, alice, path)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d for the ballot, got %d", http.StatusOK, status)
+	}
+	form := url.Values{"rank-0": {"2"}, "rank-1": {"1"}, "rank-2": {"3"}}
+	if status = test.post(t, alice, path, form); status != http.StatusForbidden {
+		t.Errorf("Expected status %d without CSRF token, got %d", http.StatusForbidden, status)
+	}
+	form.Set("csrf", token)
+	if status = test.post(t, alice, path, form); status != http.StatusOK {
+		t.Fatalf("Expected status %d when casting the vote, got %d", http.StatusOK, status)
+	}
+	ranking, err := test.storage.GetSchulzeVote(test.schulze.ID, test.aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranking) != 3 || ranking[0] != 2 || ranking[1] != 1 || ranking[2] != 3 {
+		t.Errorf("Expected ranking [2 1 3] after casting the vote, got %v", ranking)
+	}
+
+	form = url.Values{"csrf": {token}, "rank-0": {"1"}, "rank-1": {""}, "rank-2": {"2"}}
+	if status = test.post(t, alice, path, form); status != http.StatusOK {
+		t.Fatalf("Expected status %d when updating the vote, got %d", http.StatusOK, status)
+	}
+	if ranking, err = test.storage.GetSchulzeVote(test.schulze.ID, test.aliceID); err != nil {
+		t.Fatal(err)
+	}
+	if len(ranking) != 3 || ranking[0] != 1 || ranking[1] != Unranked || ranking[2] != 2 {
+		t.Errorf("Expected ranking [1 %d 2] after updating the vote, got %v", Unranked, ranking)
+	}
+	form.Set("rank-0", "4")
+	if status = test.post(t, alice, path, form); status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid position, got %d", http.StatusBadRequest, status)
+	}
+
+	// abstentions must be explicit
+	form = url.Values{"csrf": {token}, "rank-0": {""}, "rank-1": {""}, "rank-2": {""}}
+	if status = test.post(t, alice, path, form); status != http.StatusBadRequest {
+		t.Errorf("Expected status %d without ranked options, got %d", http.StatusBadRequest, status)
+	}
+	form.Set("abstain", "1")
+	if status = test.post(t, alice, path, form); status != http.StatusOK {
+		t.Fatalf("Expected status %d when abstaining, got %d", http.StatusOK, status)
+	}
+	if ranking, err = test.storage.GetSchulzeVote(test.schulze.ID, test.aliceID); err != nil || !RankingAbstains(ranking) {
+		t.Errorf("Expected an abstention after abstaining, got %v (error %v)", ranking, err)
+	}
+	form.Set("rank-0", "1")
+	if status = test.post(t, alice, path, form); status != http.StatusBadRequest {
+		t.Errorf("Expected status %d when ranking options and abstaining, got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestWebLoginLogout(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	client := test.client(t, "alice")
+	_, aliceToken := test.get(t, client, "/")
+	// logging in again must not keep anything from the old session
+	if status := test.post(t, client, "/login", url.Values{"username": {"mallory"}, "password": {testPassword}}); status != http.StatusFound {
+		t.Fatalf("Expected redirect after logging in, got status %d", status)
+	}
+	_, malloryToken := test.get(t, client, "/")
+	if malloryToken == "" || malloryToken == aliceToken {
+		t.Errorf("Expected a new CSRF token after logging in, got %q", malloryToken)
+	}
+	if status, _ := test.get(t, client, fmt.Sprintf("/vote/median?id=%d", test.median.ID)); status != http.StatusForbidden {
+		t.Errorf("Expected status %d for mallory, got %d", http.StatusForbidden, status)
+	}
+	// logging out requires a POST request with the CSRF token
+	if status, _ := test.get(t, client, "/logout"); status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for logout with GET, got %d", http.StatusMethodNotAllowed, status)
+	}
+	if status := test.post(t, client, "/logout", url.Values{"csrf": {aliceToken}}); status != http.StatusForbidden {
+		t.Errorf("Expected status %d for logout with an old CSRF token, got %d", http.StatusForbidden, status)
+	}
+	if status, _ := test.get(t, client, "/"); status != http.StatusOK {
+		t.Errorf("Expected to be logged in, got status %d", status)
+	}
+	if status := test.post(t, client, "/logout", url.Values{"csrf": {malloryToken}}); status != http.StatusFound {
+		t.Errorf("Expected redirect after logging out, got status %d", status)
+	}
+	if status, _ := test.get(t, client, "/"); status != http.StatusFound {
+		t.Errorf("Expected redirect to the login page after logging out, got status %d", status)
+	}
+}
+
+func TestWebUploadVoters(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	admin := test.client(t, "admin")
+	_, token := test.get(t, admin, "/")
+	form := url.Values{"csrf": {token}, "revision": {fmt.Sprint(test.revisionID)}}
+	for _, content := range []string{"* Carol: 1\n* Carol: 2\n", "* Carol: 1\n* Alice: 2\n"} {
+		if status := test.upload(t, admin, "/revision/upload", form, content); status != http.StatusBadRequest {
+			t.Errorf("Expected status %d when uploading %q, got %d", http.StatusBadRequest, content, status)
+		}
+	}
+	if status := test.upload(t, admin, "/revision/upload", form, "* Carol: 1\n"); status != http.StatusFound {
+		t.Errorf("Expected redirect after uploading voters, got status %d", status)
+	}
+	voters, err := test.storage.ListVoters(test.revisionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voters) != 3 {
+		t.Errorf("Expected three voters after the upload, got %v", voters)
+	}
+}
+
+// TestWebMeeting prepares a meeting as admin: It adds a category and a
+// revision, uploads the voters and the example agenda and shows them.
+func TestWebMeeting(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	admin := test.client(t, "admin")
+	_, token := test.get(t, admin, "/")
+	if status := test.post(t, admin, "/category/add", url.Values{"csrf": {token}, "name": {"AStA"}}); status != http.StatusFound {
+		t.Fatalf("Expected redirect after adding a category, got status %d", status)
+	}
+	categories, err := test.storage.ListCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 2 {
+		t.Fatalf("Expected two categories, got %v", categories)
+	}
+	category := categories[0]
+	if category.Name != "AStA" {
+		category = categories[1]
+	}
+	if status, body := test.page(t, admin, "/"); status != http.StatusOK || !strings.Contains(body, "AStA") {
+		t.Errorf("Expected the category on the index page, got status %d", status)
+	}
+	form := url.Values{"csrf": {token}, "category": {fmt.Sprint(category.ID)}}
+	if status := test.post(t, admin, "/revision/add", form); status != http.StatusFound {
+		t.Fatalf("Expected redirect after adding a revision, got status %d", status)
+	}
+	revisions, err := test.storage.ListVotersRevision(category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("Expected one revision, got %v", revisions)
+	}
+	revisionID := revisions[0].ID
+	form = url.Values{"csrf": {token}, "revision": {fmt.Sprint(revisionID)}}
+	voters, err := ioutil.ReadFile(path.Join("examples", "voters.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := test.upload(t, admin, "/revision/upload", form, string(voters)); status != http.StatusFound {
+		t.Fatalf("Expected redirect after uploading the voters, got status %d", status)
+	}
+	agenda, err := ioutil.ReadFile(path.Join("examples", "stura-9.5.17.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := test.upload(t, admin, "/collection/upload", form, "invalid"); status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid collection, got %d", http.StatusBadRequest, status)
+	}
+	if status := test.upload(t, admin, "/collection/upload", form, string(agenda)); status != http.StatusFound {
+		t.Fatalf("Expected redirect after uploading the collection, got status %d", status)
+	}
+	collections, err := test.storage.ListVotingCollections(revisionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 1 {
+		t.Fatalf("Expected one collection, got %v", collections)
+	}
+	status, body := test.page(t, admin, fmt.Sprintf("/revision?id=%d", revisionID))
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d for the revision, got %d", http.StatusOK, status)
+	}
+	if !strings.Contains(body, collections[0].Name) || !strings.Contains(body, "Fachbereich Bla") {
+		t.Errorf("Expected the voters and the collection %s on the revision page", collections[0].Name)
+	}
+	status, body = test.page(t, admin, fmt.Sprintf("/collection?id=%d", collections[0].ID))
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d for the collection, got %d", http.StatusOK, status)
+	}
+	if !strings.Contains(body, "Abstimmungen") {
+		t.Error("Expected the votings of the example agenda on the collection page")
+	}
+}
+
+func TestWebNotFound(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	admin := test.client(t, "admin")
+	alice := test.client(t, "alice")
+	for _, path := range []string{"/collection?id=42", "/median?id=42", "/schulze?id=42"} {
+		if status, _ := test.get(t, admin, path); status != http.StatusNotFound {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusNotFound, path, status)
+		}
+	}
+	for _, path := range []string{"/vote/median?id=42", "/vote/schulze?id=42"} {
+		if status, _ := test.get(t, alice, path); status != http.StatusNotFound {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusNotFound, path, status)
+		}
+	}
+}
+
+func TestWebResultWithoutMajority(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	revisionID, err := test.storage.GetMedianVotingRevision(test.median.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	median := &MedianVoting{Name: "Budget", MaxValue: 1000, PercentRequired: -1.0}
+	schulze := &SchulzeVoting{Name: "Chair", Options: []string{"A", "No"}, PercentRequired: -1.0, Position: 1}
+	collection := &VotingCollection{Name: "Old format", Date: time.Date(2017, time.May, 9, 0, 0, 0, 0, time.UTC),
+		Groups: []*VotingGroup{&VotingGroup{Name: "Finances",
+			MedianVotings: []*MedianVoting{median}, SchulzeVotings: []*SchulzeVoting{schulze}}}}
+	if err = test.storage.InsertVotingCollection(revisionID, collection); err != nil {
+		t.Fatal(err)
+	}
+	admin := test.client(t, "admin")
+	for _, path := range []string{fmt.Sprintf("/median?id=%d", median.ID), fmt.Sprintf("/schulze?id=%d", schulze.ID)} {
+		if status, _ := test.get(t, admin, path); status != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s without a default majority, got %d", http.StatusBadRequest, path, status)
+		}
+		if status, _ := test.get(t, admin, path+"&majority=1/2"); status != http.StatusOK {
+			t.Errorf("Expected status %d for %s with a default majority, got %d", http.StatusOK, path, status)
+		}
+	}
+}
+
+func TestWebLinkUser(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	admin := test.client(t, "admin")
+	_, token := test.get(t, admin, fmt.Sprintf("/revision?id=%d", test.revisionID))
+	if token == "" {
+		t.Fatal("Expected a CSRF token in the revision page")
+	}
+	bob, err := test.storage.GetVoterByName(test.revisionID, "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRevision, err := test.storage.InsertVotersRevision(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = test.storage.InsertVoters(otherRevision, []*Voter{NewVoter("Carol", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	carol, err := test.storage.GetVoterByName(otherRevision, "Carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the voter must be in the posted revision
+	form := url.Values{"csrf": {token}, "revision": {fmt.Sprint(test.revisionID)},
+		"username": {"mallory"}, "voter": {fmt.Sprint(carol.ID)}}
+	if status := test.post(t, admin, "/revision/link", form); status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a voter of another revision, got %d", http.StatusBadRequest, status)
+	}
+	if _, err = test.storage.GetUserVoter(3, otherRevision); err == nil {
+		t.Error("Expected no link for a voter of another revision")
+	}
+	form.Set("voter", fmt.Sprint(bob.ID))
+	if status := test.post(t, admin, "/revision/link", form); status != http.StatusFound {
+		t.Errorf("Expected redirect after linking the user, got status %d", status)
+	}
+	if voter, err := test.storage.GetUserVoter(3, test.revisionID); err != nil || voter.ID != bob.ID {
+		t.Errorf("Expected mallory to vote for Bob, got %v (error %v)", voter, err)
+	}
+	mallory := test.client(t, "mallory")
+	if status, _ := test.get(t, mallory, fmt.Sprintf("/collection?id=%d", test.collection.ID)); status != http.StatusOK {
+		t.Errorf("Expected status %d for the collection after linking, got %d", http.StatusOK, status)
+	}
+}
+
+func TestWebUnlinkUser(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	alice := test.client(t, "alice")
+	if status, body := test.page(t, alice, "/"); status != http.StatusOK || !strings.Contains(body, test.collection.Name) {
+		t.Errorf("Expected the collection on the index page of a voter, got status %d", status)
+	}
+	mallory := test.client(t, "mallory")
+	if status, body := test.page(t, mallory, "/"); status != http.StatusOK || strings.Contains(body, test.collection.Name) {
+		t.Errorf("Expected no collection on the index page of no voter, got status %d", status)
+	}
+	// only administrators manage categories
+	_, token := test.get(t, alice, "/")
+	if status := test.post(t, alice, "/category/add", url.Values{"csrf": {token}, "name": {"AStA"}}); status != http.StatusForbidden {
+		t.Errorf("Expected status %d when a voter adds a category, got %d", http.StatusForbidden, status)
+	}
+	admin := test.client(t, "admin")
+	_, token = test.get(t, admin, fmt.Sprintf("/revision?id=%d", test.revisionID))
+	form := url.Values{"csrf": {token}, "revision": {fmt.Sprint(test.revisionID)}, "username": {"alice"}}
+	if status := test.post(t, admin, "/revision/unlink", form); status != http.StatusFound {
+		t.Errorf("Expected redirect after unlinking the user, got status %d", status)
+	}
+	if status, body := test.page(t, alice, "/"); status != http.StatusOK || strings.Contains(body, test.collection.Name) {
+		t.Errorf("Expected no collection on the index page after unlinking, got status %d", status)
+	}
+	if status, _ := test.get(t, alice, fmt.Sprintf("/vote/median?id=%d", test.median.ID)); status != http.StatusForbidden {
+		t.Errorf("Expected status %d for the ballot after unlinking, got %d", http.StatusForbidden, status)
+	}
+	form.Set("username", "nobody")
+	if status := test.post(t, admin, "/revision/unlink", form); status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown user, got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestWebInvalidEvaluationOptions(t *testing.T) {
+	test := newWebTest(t)
+	defer test.server.Close()
+	admin := test.client(t, "admin")
+	invalid := []string{"basis=present", "basis=present&present=4", "basis=present&present=-1",
+		"quorum=3/2", "majority=1", "basis=unknown", "ties=random&seed=x"}
+	for _, path := range []string{fmt.Sprintf("/median?id=%d", test.median.ID), fmt.Sprintf("/schulze?id=%d", test.schulze.ID)} {
+		for _, query := range invalid {
+			if status, _ := test.get(t, admin, path+"&"+query); status != http.StatusBadRequest {
+				t.Errorf("Expected status %d for %s&%s, got %d", http.StatusBadRequest, path, query, status)
+			}
+		}
+		if status, _ := test.get(t, admin, path+"&basis=present&present=3"); status != http.StatusOK {
+			t.Errorf("Expected status %d for %s with all voters present, got %d", http.StatusOK, path, status)
+		}
+	}
+}
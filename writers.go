@@ -0,0 +1,160 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// checkWritable checks that the name can be written in the text formats
+// and is parsed back to the same string.
+func checkWritable(kind, name string) error {
+	if err := validateVotingsString(name); err != nil {
+		return fmt.Errorf("Invalid %s \"%s\": %s", kind, name, err.Error())
+	}
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("Invalid %s \"%s\": Leading or trailing whitespace", kind, name)
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return fmt.Errorf("Invalid %s \"%s\": Line breaks are not allowed", kind, name)
+	}
+	return nil
+}
+
+// WriteVoters writes the voters in the format parsed by ParseVoters, one
+// voter per line.
+func WriteVoters(w io.Writer, voters []*Voter) error {
+	writer := bufio.NewWriter(w)
+	for _, voter := range voters {
+		if err := checkWritable("voter name", voter.Name); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(writer, "* %s: %d\n", voter.Name, voter.Weight); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// WriteVotingCollection writes the collection in the format parsed by
+// ParseVotingCollection.
+// The votings of each group are written in the order returned by
+// VotingGroup.Votings.
+// The format has no syntax for empty groups, only the last group of a
+// collection may be empty.
+// The PercentRequired of each voting is written if it is set, i.e. between
+// 0 and 1, ids are not written.
+func WriteVotingCollection(w io.Writer, collection *VotingCollection) error {
+	if err := checkCollectionWritable(collection); err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "# %s: %s\n", collection.Name, collection.Date.Format("02.01.2006"))
+	for _, group := range collection.Groups {
+		fmt.Fprintf(writer, "\n## %s\n", group.Name)
+		for _, voting := range group.Votings() {
+			if voting.Median != nil {
+				fmt.Fprintf(writer, "\n### %s\n", voting.Median.Name)
+				writePercentRequired(writer, voting.Median.PercentRequired)
+				fmt.Fprintf(writer, "- %s\n", FormatConcurrency(voting.Median.MaxValue))
+				continue
+			}
+			fmt.Fprintf(writer, "\n### %s\n", voting.Schulze.Name)
+			writePercentRequired(writer, voting.Schulze.PercentRequired)
+			for _, option := range voting.Schulze.Options {
+				if voting.Schulze.StatusQuo != "" && option == voting.Schulze.StatusQuo {
+					fmt.Fprintf(writer, "*! %s\n", option)
+				} else {
+					fmt.Fprintf(writer, "* %s\n", option)
+				}
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+// writePercentRequired writes the majority line if percent is set.
+func writePercentRequired(w io.Writer, percent float64) {
+	if percent >= 0.0 {
+		fmt.Fprintf(w, "majority: %s\n", FormatPercentRequired(percent))
+	}
+}
+
+// checkPercentWritable checks that the percentage is either -1.0 (not set) or
+// between 0 and 1.
+func checkPercentWritable(votingName string, percent float64) error {
+	if percent != -1.0 && Majority(percent).Validate() != nil {
+		return fmt.Errorf("Invalid majority %v in voting \"%s\": Must be at least 0 and less than 1", percent, votingName)
+	}
+	return nil
+}
+
+// checkCollectionWritable checks that WriteVotingCollection writes a text
+// that is parsed back to the same collection.
+func checkCollectionWritable(collection *VotingCollection) error {
+	if err := checkWritable("collection name", collection.Name); err != nil {
+		return err
+	}
+	for i, group := range collection.Groups {
+		if err := checkWritable("group name", group.Name); err != nil {
+			return err
+		}
+		if i < len(collection.Groups)-1 && len(group.MedianVotings) == 0 && len(group.SchulzeVotings) == 0 {
+			return fmt.Errorf("Group \"%s\" has no votings, only the last group may be empty", group.Name)
+		}
+		for _, voting := range group.MedianVotings {
+			if err := checkWritable("voting name", voting.Name); err != nil {
+				return err
+			}
+			if voting.MaxValue < 0 {
+				return fmt.Errorf("Invalid value %s in voting \"%s\": Must not be negative", FormatConcurrency(voting.MaxValue), voting.Name)
+			}
+			if err := checkPercentWritable(voting.Name, voting.PercentRequired); err != nil {
+				return err
+			}
+		}
+		for _, voting := range group.SchulzeVotings {
+			if err := checkWritable("voting name", voting.Name); err != nil {
+				return err
+			}
+			if len(voting.Options) == 0 {
+				return fmt.Errorf("Voting \"%s\" has no options", voting.Name)
+			}
+			if voting.StatusQuoIndex() < 0 {
+				return fmt.Errorf("Status quo \"%s\" of voting \"%s\" is not an option", voting.StatusQuo, voting.Name)
+			}
+			if err := checkPercentWritable(voting.Name, voting.PercentRequired); err != nil {
+				return err
+			}
+			for _, option := range voting.Options {
+				if err := checkWritable("option", option); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
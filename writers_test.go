@@ -0,0 +1,105 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017 Fabian Wenzelmann
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sturavoting
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteVotersRoundTrip(t *testing.T) {
+	voters := []*Voter{NewVoter("Fachbereich Bla", 2), NewVoter("Initiative: Blubb", -1), NewVoter("*Stern*", 0)}
+	var buf bytes.Buffer
+	if err := WriteVoters(&buf, voters); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseVoters(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, voters) {
+		t.Errorf("Expected %v after round trip, got %v", voters, parsed)
+	}
+	for _, name := range []string{"", " Bob", "Bob\nAlice", strings.Repeat("a", 151)} {
+		if err := WriteVoters(&buf, []*Voter{NewVoter(name, 1)}); err == nil {
+			t.Errorf("Expected error when writing voter \"%s\"", name)
+		}
+	}
+}
+
+func TestWriteVotingCollectionRoundTrip(t *testing.T) {
+	collection := &VotingCollection{Name: "StuRa: 09.05.17",
+		Date: time.Date(2017, time.May, 9, 0, 0, 0, 0, time.UTC),
+		Groups: []*VotingGroup{
+			&VotingGroup{Name: "TOP 1",
+				MedianVotings: []*MedianVoting{&MedianVoting{Name: "Exkursion", MaxValue: 108660, PercentRequired: -1.0, Position: 0},
+					&MedianVoting{Name: "Druckkosten", MaxValue: 5, PercentRequired: 0.5, Position: 2}},
+				SchulzeVotings: []*SchulzeVoting{&SchulzeVoting{Name: "Verteilung", Options: []string{"* A", "Nein", "- B"}, StatusQuo: "Nein", PercentRequired: 2.0 / 3.0, Position: 1}}},
+			&VotingGroup{Name: "TOP 2", MedianVotings: []*MedianVoting{},
+				SchulzeVotings: []*SchulzeVoting{&SchulzeVoting{Name: "### Abstimmung", Options: []string{"Ja"}, PercentRequired: -1.0}}},
+			&VotingGroup{Name: "## Leer", MedianVotings: []*MedianVoting{}, SchulzeVotings: []*SchulzeVoting{}},
+		}}
+	var buf bytes.Buffer
+	if err := WriteVotingCollection(&buf, collection); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseVotingCollection(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, collection) {
+		t.Errorf("Expected\n%s\nafter round trip, got\n%s", collection, parsed)
+	}
+	// an empty group that is not the last one can't be written
+	collection.Groups[0], collection.Groups[2] = collection.Groups[2], collection.Groups[0]
+	if err := WriteVotingCollection(&buf, collection); err == nil {
+		t.Error("Expected error when writing an empty group that is not the last one")
+	}
+}
+
+func TestWriteExampleCollection(t *testing.T) {
+	f, err := os.Open("examples/stura-9.5.17.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	collection, err := ParseVotingCollection(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteVotingCollection(&buf, collection); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseVotingCollection(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, collection) {
+		t.Errorf("Expected\n%s\nafter round trip, got\n%s", collection, parsed)
+	}
+}